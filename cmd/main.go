@@ -6,29 +6,100 @@
 // Usage:
 //
 //	transport-stdio --orchestrator-addr localhost:9100 --certs-dir ~/.orchestra/certs
+//
+// With --http-addr the same bridge is served as an MCP Streamable HTTP
+// endpoint at /mcp instead of on stdin/stdout. An address without a host
+// binds to 127.0.0.1, since the endpoint has no authentication:
+//
+//	transport-stdio --orchestrator-addr localhost:9100 --http-addr 127.0.0.1:8080
 package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
+	"net"
+	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"syscall"
+	"time"
 
-	"github.com/orchestra-mcp/sdk-go/plugin"
 	"github.com/orchestra-mcp/plugin-transport-stdio/internal"
+	"github.com/orchestra-mcp/sdk-go/plugin"
+	"github.com/orchestra-mcp/sdk-go/protocol"
 )
 
 func main() {
 	orchestratorAddr := flag.String("orchestrator-addr", "localhost:9100", "Address of the orchestrator")
 	certsDir := flag.String("certs-dir", plugin.DefaultCertsDir, "Directory for mTLS certificates")
+	pageSize := flag.Int("page-size", 0, "Maximum items per tools/prompts/resources list page (0 disables pagination)")
+	httpAddr := flag.String("http-addr", "", "Serve MCP Streamable HTTP on this address instead of stdio (e.g. 127.0.0.1:8080; a bare :port binds to 127.0.0.1)")
+	var allowedOrigins []string
+	flag.Func("allow-origin", "Browser origin allowed to call the HTTP endpoint besides localhost, e.g. https://app.example.com (repeatable)", func(v string) error {
+		allowedOrigins = append(allowedOrigins, v)
+		return nil
+	})
+	httpMaxSessions := flag.Int("http-max-sessions", 100, "Maximum open HTTP sessions; further initialize requests get 503 (0 for unlimited)")
+	httpSessionIdle := flag.Duration("http-session-idle", 30*time.Minute, "Close HTTP sessions without requests or an open event stream for this long (0 keeps them until deleted)")
+	reconnectGiveUp := flag.Duration("reconnect-give-up", 5*time.Minute, "How long to retry a dropped orchestrator connection before failing queued requests (0 retries forever)")
+	reconnectQueue := flag.Int("reconnect-queue", 64, "Maximum requests held while reconnecting to the orchestrator")
+	maxInFlight := flag.Int("max-inflight", 0, "Maximum concurrent tools/call requests, e.g. 32 (0 for unlimited)")
+	maxInFlightPerTool := flag.Int("max-inflight-per-tool", 0, "Maximum concurrent tools/call requests per tool (0 for unlimited)")
+	maxQueue := flag.Int("max-queue", 0, "Maximum tools/call requests waiting for a slot before calls are rejected, e.g. 128 (0 rejects calls over a limit at once)")
+	toolLimits := map[string]int{}
+	flag.Func("tool-limit", "Per-tool concurrency limit as name=n (repeatable)", func(v string) error {
+		name, n, ok := strings.Cut(v, "=")
+		limit, err := strconv.Atoi(n)
+		if !ok || name == "" || err != nil {
+			return fmt.Errorf("want name=n, got %q", v)
+		}
+		toolLimits[name] = limit
+		return nil
+	})
+	requestTimeout := flag.Duration("request-timeout", 0, "Default timeout for requests to the orchestrator, e.g. 5m (0 for none)")
+	maxTimeout := flag.Duration("max-timeout", 0, "Longest timeout a client may request via _meta.timeoutMs (0 only allows shorter timeouts)")
+	methodTimeouts := map[string]time.Duration{}
+	flag.Func("method-timeout", "Per-method timeout as method=duration (repeatable)", durationMapFlag(methodTimeouts))
+	toolTimeouts := map[string]time.Duration{}
+	flag.Func("tool-timeout", "Per-tool timeout as name=duration, e.g. send_message=30m (repeatable)", durationMapFlag(toolTimeouts))
+	maxMessageSize := flag.Int("max-message-size", 10*1024*1024, "Largest incoming JSON-RPC message in bytes (0 for unlimited)")
+	validateOutput := flag.Bool("validate-output", false, "Reject tool results that do not match the tool's declared outputSchema")
+	clientRequestTimeout := flag.Duration("client-request-timeout", 2*time.Minute, "How long to wait for the client to answer requests such as sampling (0 for no limit)")
+	toolOverridesFile := flag.String("tool-overrides", "", "JSON file of tool title/annotation overrides keyed by tool name or pattern")
+	resourceCacheEntries := flag.Int("resource-cache-entries", 0, "Maximum resources/read results cached in memory (0 disables the cache; requires --resource-cache-ttl, since cached reads are only refreshed once the TTL passes)")
+	resourceCacheBytes := flag.Int64("resource-cache-bytes", 32*1024*1024, "Maximum summed size in bytes of cached resources (0 for unlimited)")
+	resourceCacheTTL := flag.Duration("resource-cache-ttl", 5*time.Minute, "How long a cached resource is served before it is read again")
+	resourceNamespacesFile := flag.String("resource-namespaces", "", "JSON file of storage namespaces to expose as resources (default features/, notes/ and docs/)")
 	flag.Parse()
 
 	if *orchestratorAddr == "" {
 		log.Fatal("--orchestrator-addr is required")
 	}
+	if *resourceCacheEntries > 0 && *resourceCacheTTL <= 0 {
+		log.Fatal("--resource-cache-entries requires a positive --resource-cache-ttl")
+	}
+
+	var toolOverrides map[string]internal.ToolOverride
+	if *toolOverridesFile != "" {
+		var err error
+		if toolOverrides, err = internal.LoadToolOverrides(*toolOverridesFile); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	var resourceNamespaces []internal.ResourceNamespace
+	if *resourceNamespacesFile != "" {
+		var err error
+		if resourceNamespaces, err = internal.LoadResourceNamespaces(*resourceNamespacesFile); err != nil {
+			log.Fatal(err)
+		}
+	}
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -49,17 +120,93 @@ func main() {
 		log.Fatalf("client TLS config: %v", err)
 	}
 
-	// Connect to the orchestrator over QUIC.
-	client, err := plugin.NewOrchestratorClient(ctx, *orchestratorAddr, clientTLS)
-	if err != nil {
+	// Connect to the orchestrator over QUIC, re-dialing if the connection drops.
+	// Link changes are reported to the client through notify, which is set
+	// once the transport exists. The connection may drop before that, so
+	// notify is read and set atomically.
+	var notify atomic.Pointer[func(level protocol.MCPLogLevel, data string)]
+	client := internal.NewReconnectingSender(
+		func(ctx context.Context) (internal.OrchestratorConn, error) {
+			c, err := plugin.NewOrchestratorClient(ctx, *orchestratorAddr, clientTLS)
+			if err != nil {
+				return nil, err
+			}
+			return c, nil
+		},
+		internal.WithGiveUpAfter(*reconnectGiveUp),
+		internal.WithMaxQueued(*reconnectQueue),
+		internal.WithLinkNotifier(func(state internal.LinkState, err error) {
+			level, msg := linkMessage(state, err)
+			fmt.Fprintf(os.Stderr, "transport.stdio: %s\n", msg)
+			if fn := notify.Load(); fn != nil {
+				(*fn)(level, msg)
+			}
+		}),
+	)
+	if err := client.Connect(ctx); err != nil {
 		log.Fatalf("connect to orchestrator at %s: %v", *orchestratorAddr, err)
 	}
 	defer client.Close()
 
 	fmt.Fprintf(os.Stderr, "transport.stdio: connected to orchestrator at %s\n", *orchestratorAddr)
 
+	opts := []func(*internal.StdioTransport){
+		internal.WithPageSize(*pageSize),
+		internal.WithMaxMessageSize(*maxMessageSize),
+		internal.WithOutputValidation(*validateOutput),
+		internal.WithToolOverrides(toolOverrides),
+		internal.WithResourceNamespaces(resourceNamespaces),
+		internal.WithClientRequestTimeout(*clientRequestTimeout),
+		internal.WithCallLimits(internal.CallLimits{
+			MaxInFlight:        *maxInFlight,
+			MaxInFlightPerTool: *maxInFlightPerTool,
+			PerTool:            toolLimits,
+			MaxQueue:           *maxQueue,
+		}),
+		internal.WithRequestTimeouts(internal.RequestTimeouts{
+			Default:   *requestTimeout,
+			PerMethod: methodTimeouts,
+			PerTool:   toolTimeouts,
+			Max:       *maxTimeout,
+		}),
+	}
+
+	if *resourceCacheEntries > 0 {
+		opts = append(opts, internal.WithResourceCache(internal.ResourceCacheConfig{
+			MaxEntries: *resourceCacheEntries,
+			MaxBytes:   *resourceCacheBytes,
+			TTL:        *resourceCacheTTL,
+		}))
+	}
+
+	if *httpAddr != "" {
+		addr := loopbackDefault(*httpAddr)
+		h := internal.NewHTTPTransport(client, append(opts,
+			internal.WithAllowedOrigins(allowedOrigins...),
+			internal.WithMaxSessions(*httpMaxSessions),
+			internal.WithSessionIdleTimeout(*httpSessionIdle),
+		)...)
+		logLink := func(level protocol.MCPLogLevel, data string) {
+			h.SendLogNotification(level, "transport.stdio", data)
+		}
+		notify.Store(&logLink)
+		fmt.Fprintf(os.Stderr, "transport.stdio: serving MCP over HTTP at %s/mcp\n", addr)
+		if !isLoopback(addr) {
+			fmt.Fprintf(os.Stderr, "transport.stdio: warning: %s is reachable from other hosts and the endpoint has no authentication\n", addr)
+		}
+		if err := serveHTTP(ctx, addr, h); err != nil {
+			log.Fatalf("transport.stdio: %v", err)
+		}
+		fmt.Fprintf(os.Stderr, "transport.stdio: shutting down\n")
+		return
+	}
+
 	// Start the stdio read/write loop.
-	transport := internal.NewStdioTransport(client, os.Stdin, os.Stdout)
+	transport := internal.NewStdioTransport(client, os.Stdin, os.Stdout, opts...)
+	logLink := func(level protocol.MCPLogLevel, data string) {
+		transport.SendLogNotification(level, "transport.stdio", data)
+	}
+	notify.Store(&logLink)
 	if err := transport.Run(ctx); err != nil {
 		if ctx.Err() != nil {
 			// Graceful shutdown.
@@ -69,3 +216,78 @@ func main() {
 		log.Fatalf("transport.stdio: %v", err)
 	}
 }
+
+// durationMapFlag returns a flag.Func parser that adds key=duration values to m.
+func durationMapFlag(m map[string]time.Duration) func(string) error {
+	return func(v string) error {
+		key, ds, ok := strings.Cut(v, "=")
+		d, err := time.ParseDuration(ds)
+		if !ok || key == "" || err != nil {
+			return fmt.Errorf("want key=duration, got %q", v)
+		}
+		m[key] = d
+		return nil
+	}
+}
+
+// linkMessage describes an orchestrator link change for the client log. The
+// restore message is a warning like the loss it ends, so clients at the
+// default log level see both.
+func linkMessage(state internal.LinkState, err error) (protocol.MCPLogLevel, string) {
+	switch state {
+	case internal.LinkDown:
+		return protocol.LogLevelWarning, fmt.Sprintf("orchestrator connection lost, reconnecting: %v", err)
+	case internal.LinkUp:
+		return protocol.LogLevelWarning, "orchestrator connection restored"
+	default:
+		return protocol.LogLevelError, fmt.Sprintf("orchestrator unreachable, giving up: %v", err)
+	}
+}
+
+// loopbackDefault binds an address without a host, such as ":8080", to
+// 127.0.0.1 rather than to every interface.
+func loopbackDefault(addr string) string {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil || host != "" {
+		return addr
+	}
+	return net.JoinHostPort("127.0.0.1", port)
+}
+
+// isLoopback reports whether an address only accepts local connections.
+func isLoopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// serveHTTP serves the Streamable HTTP endpoint at /mcp until ctx is cancelled.
+// Request contexts derive from ctx so open event streams end on shutdown.
+func serveHTTP(ctx context.Context, addr string, h *internal.HTTPTransport) error {
+	mux := http.NewServeMux()
+	mux.Handle("/mcp", h)
+	srv := &http.Server{
+		Addr:        addr,
+		Handler:     mux,
+		BaseContext: func(net.Listener) context.Context { return ctx },
+	}
+
+	go h.Run(ctx)
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("serve http: %w", err)
+	}
+	return nil
+}
@@ -4,6 +4,8 @@ package transportstdio
 import (
 	"context"
 	"io"
+	"net/http"
+	"time"
 
 	pluginv1 "github.com/orchestra-mcp/gen-go/orchestra/plugin/v1"
 	"github.com/orchestra-mcp/plugin-transport-stdio/internal"
@@ -45,6 +47,142 @@ func WithServerInfo(info protocol.MCPServerInfo) TransportOption {
 	}
 }
 
+// WithPageSize sets the maximum number of items returned per page by the
+// tools/list, prompts/list and resources/list methods. A size of 0 disables
+// pagination.
+func WithPageSize(n int) TransportOption {
+	return func(t *internal.StdioTransport) {
+		internal.WithPageSize(n)(t)
+	}
+}
+
+// WithAllowedOrigins lets browser pages from the given origins call the
+// Streamable HTTP endpoint. Pages served from localhost are always allowed;
+// requests from other origins are rejected with 403 Forbidden.
+func WithAllowedOrigins(origins ...string) TransportOption {
+	return func(t *internal.StdioTransport) {
+		internal.WithAllowedOrigins(origins...)(t)
+	}
+}
+
+// WithMaxSessions limits how many sessions the Streamable HTTP endpoint keeps
+// open; further initialize requests are rejected with 503 Service
+// Unavailable. A limit of 0 means unlimited. The default is 100.
+func WithMaxSessions(n int) TransportOption {
+	return func(t *internal.StdioTransport) {
+		internal.WithMaxSessions(n)(t)
+	}
+}
+
+// WithSessionIdleTimeout closes Streamable HTTP sessions that have had no
+// request, and no open event stream, for the given duration. A timeout of 0
+// keeps sessions until the client deletes them. The default is 30 minutes.
+func WithSessionIdleTimeout(d time.Duration) TransportOption {
+	return func(t *internal.StdioTransport) {
+		internal.WithSessionIdleTimeout(d)(t)
+	}
+}
+
+// WithMaxMessageSize sets the largest incoming JSON-RPC message in bytes. A
+// larger message is answered with an error without ending the session. A size
+// of 0 allows messages of any size.
+func WithMaxMessageSize(n int) TransportOption {
+	return func(t *internal.StdioTransport) {
+		internal.WithMaxMessageSize(n)(t)
+	}
+}
+
+// WithOutputValidation enables validation of tool results against the
+// outputSchema declared by each tool. Mismatching results are returned to the
+// client as tool errors.
+func WithOutputValidation(enabled bool) TransportOption {
+	return func(t *internal.StdioTransport) {
+		internal.WithOutputValidation(enabled)(t)
+	}
+}
+
+// WithClientRequestTimeout sets how long the transport waits for the client to
+// answer a request it sent, such as sampling/createMessage. A timeout of 0
+// waits until the session ends.
+func WithClientRequestTimeout(d time.Duration) TransportOption {
+	return func(t *internal.StdioTransport) {
+		internal.WithClientRequestTimeout(d)(t)
+	}
+}
+
+// ToolAnnotations are MCP hints about a tool's behaviour, such as whether it is
+// read-only or destructive.
+type ToolAnnotations = internal.ToolAnnotations
+
+// ToolOverride replaces the title and annotations of matching tools.
+type ToolOverride = internal.ToolOverride
+
+// WithToolOverrides sets title and annotation overrides for tools, keyed by
+// tool name or by a path.Match pattern such as "delete_*".
+func WithToolOverrides(overrides map[string]ToolOverride) TransportOption {
+	return func(t *internal.StdioTransport) {
+		internal.WithToolOverrides(overrides)(t)
+	}
+}
+
+// CallLimits bounds concurrent tools/call dispatch. A zero limit means
+// unlimited.
+type CallLimits = internal.CallLimits
+
+// CallStats reports queueing of tools/call requests.
+type CallStats = internal.CallStats
+
+// WithCallLimits bounds concurrent tools/call dispatch. Calls beyond the limits
+// wait in a queue of at most limits.MaxQueue; further calls are rejected with a
+// server busy error. Transports sharing the option value share the limits.
+func WithCallLimits(limits CallLimits) TransportOption {
+	opt := internal.WithCallLimits(limits)
+	return func(t *internal.StdioTransport) {
+		opt(t)
+	}
+}
+
+// RequestTimeouts bounds how long requests may take, with per-method and
+// per-tool overrides. A zero duration means no timeout.
+type RequestTimeouts = internal.RequestTimeouts
+
+// WithRequestTimeouts sets per-request timeouts. A request that times out is
+// answered with error code -32001 instead of an InternalError.
+func WithRequestTimeouts(timeouts RequestTimeouts) TransportOption {
+	return func(t *internal.StdioTransport) {
+		internal.WithRequestTimeouts(timeouts)(t)
+	}
+}
+
+// ResourceNamespace exposes the storage entries under a prefix as MCP
+// resources named orchestra://<scheme>/<id>.
+type ResourceNamespace = internal.ResourceNamespace
+
+// WithResourceNamespaces sets the storage namespaces exposed as MCP resources,
+// replacing the default features/, notes/ and docs/ namespaces.
+func WithResourceNamespaces(namespaces []ResourceNamespace) TransportOption {
+	return func(t *internal.StdioTransport) {
+		internal.WithResourceNamespaces(namespaces)(t)
+	}
+}
+
+// ResourceCacheConfig bounds the cache of resources/read results. A zero
+// limit means unlimited.
+type ResourceCacheConfig = internal.ResourceCacheConfig
+
+// ResourceCacheStats reports the use of the resource read cache.
+type ResourceCacheStats = internal.ResourceCacheStats
+
+// WithResourceCache caches resources/read results in memory, dropping entries
+// when storage events report a change or the TTL passes. Transports sharing
+// the option value share the cache.
+func WithResourceCache(cfg ResourceCacheConfig) TransportOption {
+	opt := internal.WithResourceCache(cfg)
+	return func(t *internal.StdioTransport) {
+		opt(t)
+	}
+}
+
 // Transport wraps the internal StdioTransport for public use.
 type Transport struct {
 	t *internal.StdioTransport
@@ -71,3 +209,84 @@ func (t *Transport) Run(ctx context.Context) error {
 func (t *Transport) SendToolsListChanged() {
 	t.t.SendToolsListChanged()
 }
+
+// SendPromptsListChanged sends a notifications/prompts/list_changed
+// notification to the connected client, prompting it to re-fetch the prompt list.
+func (t *Transport) SendPromptsListChanged() {
+	t.t.SendPromptsListChanged()
+}
+
+// CallStats returns tools/call queueing statistics, including time spent
+// waiting for a concurrency slot.
+func (t *Transport) CallStats() CallStats {
+	return t.t.CallStats()
+}
+
+// ResourceCacheStats returns resource read cache hits, misses and size.
+func (t *Transport) ResourceCacheStats() ResourceCacheStats {
+	return t.t.ResourceCacheStats()
+}
+
+// Root is a workspace folder the client has open.
+type Root = internal.Root
+
+// Roots returns the workspace roots reported by the client via roots/list.
+func (t *Transport) Roots() []Root {
+	return t.t.Roots()
+}
+
+// LifecycleState is the stage of the MCP session lifecycle a transport is in.
+type LifecycleState = internal.LifecycleState
+
+// Lifecycle states, in the order a session passes through them.
+const (
+	StateUninitialized = internal.StateUninitialized
+	StateInitializing  = internal.StateInitializing
+	StateReady         = internal.StateReady
+	StateShuttingDown  = internal.StateShuttingDown
+)
+
+// State returns the session's lifecycle state. Requests other than ping are
+// rejected until the client has completed the initialize handshake.
+func (t *Transport) State() LifecycleState {
+	return t.t.State()
+}
+
+// HTTPTransport serves the MCP bridge over the Streamable HTTP transport. It
+// implements http.Handler for a single MCP endpoint.
+type HTTPTransport struct {
+	h *internal.HTTPTransport
+}
+
+// NewHTTPTransport creates a Streamable HTTP transport. The options apply to
+// every MCP session the endpoint serves.
+func NewHTTPTransport(sender Sender, opts ...TransportOption) *HTTPTransport {
+	internalOpts := make([]func(*internal.StdioTransport), len(opts))
+	for i, opt := range opts {
+		internalOpts[i] = func(t *internal.StdioTransport) { opt(t) }
+	}
+	return &HTTPTransport{h: internal.NewHTTPTransport(sender, internalOpts...)}
+}
+
+// ServeHTTP implements http.Handler.
+func (t *HTTPTransport) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	t.h.ServeHTTP(w, r)
+}
+
+// Run forwards events from the configured event channel to every open
+// session until ctx is cancelled, then closes all sessions.
+func (t *HTTPTransport) Run(ctx context.Context) error {
+	return t.h.Run(ctx)
+}
+
+// SendToolsListChanged sends a notifications/tools/list_changed notification
+// to every open session.
+func (t *HTTPTransport) SendToolsListChanged() {
+	t.h.SendToolsListChanged()
+}
+
+// SendPromptsListChanged sends a notifications/prompts/list_changed
+// notification to every open session.
+func (t *HTTPTransport) SendPromptsListChanged() {
+	t.h.SendPromptsListChanged()
+}
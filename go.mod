@@ -6,6 +6,7 @@ require (
 	github.com/google/uuid v1.6.0
 	github.com/orchestra-mcp/gen-go v1.0.6
 	github.com/orchestra-mcp/sdk-go v1.0.6
+	github.com/quic-go/quic-go v0.50.0
 	google.golang.org/protobuf v1.36.11
 )
 
@@ -13,7 +14,6 @@ require (
 	github.com/go-task/slim-sprig v0.0.0-20230315185526-52ccab3ef572 // indirect
 	github.com/google/pprof v0.0.0-20250317173921-a4b03ec1a45e // indirect
 	github.com/onsi/ginkgo/v2 v2.9.5 // indirect
-	github.com/quic-go/quic-go v0.50.0 // indirect
 	github.com/stretchr/testify v1.11.1 // indirect
 	go.uber.org/mock v0.5.0 // indirect
 	golang.org/x/crypto v0.48.0 // indirect
@@ -0,0 +1,129 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/orchestra-mcp/sdk-go/protocol"
+)
+
+// isBatch reports whether a trimmed message is a JSON-RPC batch, i.e. a JSON
+// array rather than a single request object.
+func isBatch(msg []byte) bool {
+	return len(msg) > 0 && msg[0] == '['
+}
+
+// startBatch dispatches the elements of a JSON-RPC batch. Requests other than
+// tools/call are handled immediately and in order, preserving the ordering
+// guarantees of the stdio loop; tools/call elements run concurrently and may be
+// cancelled individually. The returned collect func waits for them and returns
+// the responses to send as one array, omitting notifications and cancelled
+// calls. If the batch itself is invalid, startBatch instead returns a single
+// error response that is sent on its own, as the spec requires.
+func (t *StdioTransport) startBatch(ctx context.Context, msg []byte) (collect func() []*protocol.JSONRPCResponse, errResp *protocol.JSONRPCResponse) {
+	var elems []json.RawMessage
+	if err := json.Unmarshal(msg, &elems); err != nil {
+		return nil, &protocol.JSONRPCResponse{
+			JSONRPC: "2.0",
+			ID:      nil,
+			Error: &protocol.JSONRPCError{
+				Code:    protocol.ParseError,
+				Message: fmt.Sprintf("parse error: %v", err),
+			},
+		}
+	}
+	if len(elems) == 0 {
+		return nil, &protocol.JSONRPCResponse{
+			JSONRPC: "2.0",
+			ID:      nil,
+			Error: &protocol.JSONRPCError{
+				Code:    protocol.InvalidRequest,
+				Message: "invalid request: empty batch",
+			},
+		}
+	}
+
+	resps := make([]*protocol.JSONRPCResponse, len(elems))
+	var wg sync.WaitGroup
+	for i, raw := range elems {
+		if t.deliverClientResponse(raw) {
+			continue
+		}
+		req, errResp := parseBatchElement(raw)
+		if errResp != nil {
+			resps[i] = errResp
+			continue
+		}
+		if resp, ok := t.admit(req); !ok {
+			resps[i] = resp
+			continue
+		}
+		// Elements without an ID are notifications and get no response.
+		notification := req.ID == nil
+		if req.Method == "tools/call" {
+			reqCtx, done := t.trackRequest(ctx, req.ID)
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if resp := t.dispatchTracked(ctx, reqCtx, done, req); !notification {
+					resps[i] = resp
+				}
+			}()
+			continue
+		}
+		if resp := t.dispatch(ctx, req); !notification {
+			resps[i] = resp
+		}
+	}
+
+	return func() []*protocol.JSONRPCResponse {
+		wg.Wait()
+		out := make([]*protocol.JSONRPCResponse, 0, len(resps))
+		for _, resp := range resps {
+			if resp != nil {
+				out = append(out, resp)
+			}
+		}
+		return out
+	}, nil
+}
+
+// parseBatchElement decodes one element of a batch. Elements that are not
+// request objects, lack a method, or are initialize requests (which MCP
+// forbids in a batch) yield an InvalidRequest response.
+func parseBatchElement(raw json.RawMessage) (*protocol.JSONRPCRequest, *protocol.JSONRPCResponse) {
+	var req protocol.JSONRPCRequest
+	if err := json.Unmarshal(raw, &req); err != nil {
+		return nil, &protocol.JSONRPCResponse{
+			JSONRPC: "2.0",
+			ID:      nil,
+			Error: &protocol.JSONRPCError{
+				Code:    protocol.InvalidRequest,
+				Message: fmt.Sprintf("invalid request: %v", err),
+			},
+		}
+	}
+	if req.Method == "" {
+		return nil, &protocol.JSONRPCResponse{
+			JSONRPC: "2.0",
+			ID:      req.ID,
+			Error: &protocol.JSONRPCError{
+				Code:    protocol.InvalidRequest,
+				Message: "invalid request: missing method",
+			},
+		}
+	}
+	if req.Method == "initialize" {
+		return nil, &protocol.JSONRPCResponse{
+			JSONRPC: "2.0",
+			ID:      req.ID,
+			Error: &protocol.JSONRPCError{
+				Code:    protocol.InvalidRequest,
+				Message: "invalid request: initialize must not be part of a batch",
+			},
+		}
+	}
+	return &req, nil
+}
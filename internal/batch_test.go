@@ -0,0 +1,131 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	pluginv1 "github.com/orchestra-mcp/gen-go/orchestra/plugin/v1"
+	"github.com/orchestra-mcp/sdk-go/protocol"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// echoToolSender answers every tool call successfully with the tool name.
+func echoToolSender() *mockSender {
+	return &mockSender{
+		sendFunc: func(ctx context.Context, req *pluginv1.PluginRequest) (*pluginv1.PluginResponse, error) {
+			result, _ := structpb.NewStruct(map[string]any{"text": req.GetToolCall().GetToolName()})
+			return &pluginv1.PluginResponse{
+				RequestId: req.RequestId,
+				Response: &pluginv1.PluginResponse_ToolCall{
+					ToolCall: &pluginv1.ToolResponse{Success: true, Result: result},
+				},
+			}, nil
+		},
+	}
+}
+
+// parseBatchResponse parses a JSON array of JSON-RPC responses.
+func parseBatchResponse(t *testing.T, raw string) []protocol.JSONRPCResponse {
+	t.Helper()
+	var resps []protocol.JSONRPCResponse
+	if err := json.Unmarshal([]byte(raw), &resps); err != nil {
+		t.Fatalf("parse batch response: %v\nraw: %s", err, raw)
+	}
+	return resps
+}
+
+// mixedBatch holds valid requests, a notification and invalid elements.
+const mixedBatch = `[` +
+	`{"jsonrpc":"2.0","id":1,"method":"ping"},` +
+	`1,` +
+	`{"jsonrpc":"2.0","method":"notifications/initialized"},` +
+	`{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"echo"}},` +
+	`{"jsonrpc":"2.0","id":3},` +
+	`{"jsonrpc":"2.0","id":4,"method":"initialize","params":{}}` +
+	`]`
+
+// checkMixedBatch verifies the responses to mixedBatch, in element order.
+func checkMixedBatch(t *testing.T, resps []protocol.JSONRPCResponse) {
+	t.Helper()
+	want := []struct {
+		id   any
+		code int // 0 for success
+	}{
+		{float64(1), 0},
+		{nil, protocol.InvalidRequest},
+		{float64(2), 0},
+		{float64(3), protocol.InvalidRequest},
+		{float64(4), protocol.InvalidRequest},
+	}
+	if len(resps) != len(want) {
+		t.Fatalf("expected %d responses, got %d: %+v", len(want), len(resps), resps)
+	}
+	for i, w := range want {
+		r := resps[i]
+		if r.ID != w.id {
+			t.Errorf("response %d: got ID %v, want %v", i, r.ID, w.id)
+		}
+		switch {
+		case w.code == 0 && r.Error != nil:
+			t.Errorf("response %d: unexpected error %+v", i, r.Error)
+		case w.code != 0 && (r.Error == nil || r.Error.Code != w.code):
+			t.Errorf("response %d: expected error code %d, got %+v", i, w.code, r.Error)
+		}
+	}
+}
+
+func TestBatchMixed(t *testing.T) {
+	out := runSingleRequest(t, echoToolSender(), mixedBatch)
+	checkMixedBatch(t, parseBatchResponse(t, out))
+}
+
+func TestBatchAllNotifications(t *testing.T) {
+	out := runSingleRequest(t, &mockSender{}, `[{"jsonrpc":"2.0","method":"notifications/initialized"},{"jsonrpc":"2.0","method":"notifications/cancelled","params":{"requestId":9}}]`)
+	if out != "" {
+		t.Errorf("expected no output for an all-notification batch, got %s", out)
+	}
+}
+
+func TestBatchEmpty(t *testing.T) {
+	resp := parseJSONRPCResponse(t, runSingleRequest(t, &mockSender{}, `[]`))
+	if resp.Error == nil || resp.Error.Code != protocol.InvalidRequest || resp.ID != nil {
+		t.Errorf("expected a single InvalidRequest response, got %+v", resp)
+	}
+}
+
+func TestBatchParseError(t *testing.T) {
+	resp := parseJSONRPCResponse(t, runSingleRequest(t, &mockSender{}, `[{"jsonrpc":"2.0","id":1,"method":"ping"},`))
+	if resp.Error == nil || resp.Error.Code != protocol.ParseError {
+		t.Errorf("expected a single ParseError response, got %+v", resp)
+	}
+}
+
+func TestHTTPBatch(t *testing.T) {
+	srv := httptest.NewServer(NewHTTPTransport(echoToolSender()))
+	defer srv.Close()
+	id := initHTTPSession(t, srv.URL)
+
+	resp := postJSON(t, srv.URL, id, mixedBatch)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status: got %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("read body: %v", err)
+	}
+	checkMixedBatch(t, parseBatchResponse(t, string(body)))
+
+	notifs := postJSON(t, srv.URL, id, `[{"jsonrpc":"2.0","method":"notifications/initialized"}]`)
+	if notifs.StatusCode != http.StatusAccepted {
+		t.Errorf("all-notification batch: got status %d, want %d", notifs.StatusCode, http.StatusAccepted)
+	}
+
+	empty := postJSON(t, srv.URL, id, `[]`)
+	if empty.StatusCode != http.StatusBadRequest {
+		t.Errorf("empty batch: got status %d, want %d", empty.StatusCode, http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,245 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	pluginv1 "github.com/orchestra-mcp/gen-go/orchestra/plugin/v1"
+	"github.com/orchestra-mcp/sdk-go/protocol"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// defaultClientRequestTimeout bounds how long the transport waits for the
+// client to answer a request it sent. Client requests such as sampling often
+// wait for the user to approve them, so the default is generous.
+const defaultClientRequestTimeout = 2 * time.Minute
+
+// transportPluginID identifies this transport as the source of the events it
+// publishes.
+const transportPluginID = "transport.stdio"
+
+// publishTimeout bounds how long the transport waits while publishing an
+// event to the orchestrator.
+const publishTimeout = 10 * time.Second
+
+// errSessionClosed fails client requests still pending when the session ends.
+var errSessionClosed = errors.New("session closed")
+
+// clientRequest is a JSON-RPC request sent from the transport to the client.
+type clientRequest struct {
+	JSONRPC string `json:"jsonrpc"`
+	ID      string `json:"id"`
+	Method  string `json:"method"`
+	Params  any    `json:"params,omitempty"`
+}
+
+// clientResponse is the client's JSON-RPC response to a clientRequest.
+type clientResponse struct {
+	ID     any                    `json:"id"`
+	Method string                 `json:"method"` // set only if the message is not a response
+	Result json.RawMessage        `json:"result"`
+	Error  *protocol.JSONRPCError `json:"error"`
+}
+
+// clientResult is delivered to a pending client request: the client's
+// response, or err if the request ended without one.
+type clientResult struct {
+	resp clientResponse
+	err  error
+}
+
+// clientError is an error response from the client.
+type clientError struct {
+	method string
+	err    *protocol.JSONRPCError
+}
+
+func (e *clientError) Error() string {
+	return fmt.Sprintf("client rejected %s: %s (code %d)", e.method, e.err.Message, e.err.Code)
+}
+
+// WithClientRequestTimeout sets how long the transport waits for the client to
+// answer a request it sent, such as sampling/createMessage. A timeout of 0
+// waits until the session ends.
+func WithClientRequestTimeout(d time.Duration) func(*StdioTransport) {
+	return func(t *StdioTransport) {
+		t.clientTimeout = d
+	}
+}
+
+// requestClient sends a JSON-RPC request to the client and waits for its
+// response, returning the raw result. If ctx ends or the client request
+// timeout passes first, the client is sent notifications/cancelled. Once the
+// session has closed, it fails at once with errSessionClosed.
+func (t *StdioTransport) requestClient(ctx context.Context, method string, params any) (json.RawMessage, error) {
+	if t.clientTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, t.clientTimeout)
+		defer cancel()
+	}
+
+	ch := make(chan clientResult, 1)
+	t.clientMu.Lock()
+	if t.closed {
+		t.clientMu.Unlock()
+		return nil, fmt.Errorf("%s: %w", method, errSessionClosed)
+	}
+	if t.clientPending == nil {
+		t.clientPending = make(map[string]chan clientResult)
+	}
+	t.clientSeq++
+	id := fmt.Sprintf("orchestra-%d", t.clientSeq)
+	key := requestKey(id)
+	t.clientPending[key] = ch
+	t.clientMu.Unlock()
+	defer func() {
+		t.clientMu.Lock()
+		delete(t.clientPending, key)
+		t.clientMu.Unlock()
+	}()
+
+	if err := t.writeLine(clientRequest{JSONRPC: "2.0", ID: id, Method: method, Params: params}); err != nil {
+		return nil, fmt.Errorf("send %s: %w", method, err)
+	}
+
+	select {
+	case r := <-ch:
+		if r.err != nil {
+			return nil, fmt.Errorf("%s: %w", method, r.err)
+		}
+		if r.resp.Error != nil {
+			return nil, &clientError{method: method, err: r.resp.Error}
+		}
+		return r.resp.Result, nil
+	case <-ctx.Done():
+		err := context.Cause(ctx)
+		if werr := t.writeNotification("notifications/cancelled", cancelledParams{RequestID: id, Reason: err.Error()}); werr != nil {
+			slog.Debug("failed cancelling client request", "id", id, "error", werr)
+		}
+		return nil, fmt.Errorf("%s: %w", method, err)
+	}
+}
+
+// deliverClientResponse routes a message to the pending client request it
+// answers. It reports false if the message is not a JSON-RPC response, in
+// which case the caller handles it as a request. Responses to unknown or
+// abandoned requests are dropped.
+func (t *StdioTransport) deliverClientResponse(raw []byte) bool {
+	var resp clientResponse
+	if err := json.Unmarshal(raw, &resp); err != nil || resp.Method != "" || resp.ID == nil {
+		return false
+	}
+	if resp.Result == nil && resp.Error == nil {
+		return false
+	}
+
+	key := requestKey(resp.ID)
+	t.clientMu.Lock()
+	ch, ok := t.clientPending[key]
+	delete(t.clientPending, key)
+	t.clientMu.Unlock()
+	if !ok {
+		slog.Debug("dropping response to unknown client request", "id", resp.ID)
+		return true
+	}
+	ch <- clientResult{resp: resp}
+	return true
+}
+
+// failClientRequests ends every pending client request with err and marks
+// the session closed, so that later client requests fail at once.
+func (t *StdioTransport) failClientRequests(err error) {
+	t.clientMu.Lock()
+	defer t.clientMu.Unlock()
+	t.closed = true
+	for key, ch := range t.clientPending {
+		ch <- clientResult{err: err}
+		delete(t.clientPending, key)
+	}
+}
+
+// forwardClientRequest forwards a sampling or elicitation request event to
+// the client in the background. Requests arriving after the session closed
+// are dropped.
+func (t *StdioTransport) forwardClientRequest(ev *pluginv1.EventDelivery) {
+	var started bool
+	switch ev.GetTopic() {
+	case samplingRequestTopic:
+		started = t.background(func() {
+			t.bridgeEvent(ev, samplingResultTopic, "sampling/createMessage", t.createMessage)
+		})
+	case elicitationRequestTopic:
+		started = t.background(func() {
+			t.bridgeEvent(ev, elicitationResultTopic, "elicitation/create", t.elicit)
+		})
+	}
+	if !started {
+		slog.Debug("dropping client request: session closed", "topic", ev.GetTopic())
+	}
+}
+
+// addressedTo reports whether a client request event is meant for this
+// session: it names no session in its "session_id" field, or names this one.
+// Only a ready session matches, since the orchestrator learns session IDs
+// from calls made after the handshake.
+func (t *StdioTransport) addressedTo(ev *pluginv1.EventDelivery) bool {
+	id := ev.GetPayload().GetFields()["session_id"].GetStringValue()
+	return id == "" || (t.State() == StateReady && id == t.sessionID)
+}
+
+// bridgeEvent serves a plugin's request for the client, carried by an event
+// whose payload holds a plugin-chosen request_id, the MCP params and an
+// optional timeout_ms. call performs the request; its result, or the error, is
+// published on resultTopic together with the request_id and the requesting
+// plugin's ID. bridgeEvent blocks until the client answers, so it runs in the
+// background.
+func (t *StdioTransport) bridgeEvent(ev *pluginv1.EventDelivery, resultTopic, method string, call func(context.Context, *structpb.Struct) (map[string]any, error)) {
+	fields := ev.GetPayload().GetFields()
+	requestID := fields["request_id"].GetStringValue()
+
+	ctx := context.Background()
+	if ms := fields["timeout_ms"].GetNumberValue(); ms > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(ms)*time.Millisecond)
+		defer cancel()
+	}
+
+	reply := map[string]any{"request_id": requestID, "plugin": ev.GetSourcePlugin()}
+	result, err := call(ctx, fields["params"].GetStructValue())
+	if err != nil {
+		slog.Debug("client request failed", "method", method, "request_id", requestID, "error", err)
+		reply["error"] = err.Error()
+	} else {
+		reply["result"] = result
+	}
+	if err := t.publish(resultTopic, method, reply); err != nil {
+		slog.Error("failed publishing client result", "method", method, "request_id", requestID, "error", err)
+	}
+}
+
+// publish sends an event to the orchestrator on behalf of this transport.
+func (t *StdioTransport) publish(topic, eventType string, payload map[string]any) error {
+	s, err := structpb.NewStruct(payload)
+	if err != nil {
+		return fmt.Errorf("encode %s payload: %w", topic, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), publishTimeout)
+	defer cancel()
+	_, err = t.send(ctx, &pluginv1.PluginRequest{
+		RequestId: t.requestID("pub", topic),
+		Request: &pluginv1.PluginRequest_Publish{
+			Publish: &pluginv1.Publish{
+				Topic:        topic,
+				EventType:    eventType,
+				Payload:      s,
+				SourcePlugin: transportPluginID,
+			},
+		},
+	})
+	return err
+}
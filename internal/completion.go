@@ -0,0 +1,228 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+
+	pluginv1 "github.com/orchestra-mcp/gen-go/orchestra/plugin/v1"
+	"github.com/orchestra-mcp/sdk-go/protocol"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// maxCompletionValues is the most values MCP allows in one completion result.
+const maxCompletionValues = 100
+
+// completionToolPrefix names the tools through which plugins complete the
+// arguments of their prompts. pluginv1 has no completion request, so a plugin
+// that can complete the arguments of prompt P exposes a tool named
+// "__complete_P". It is called with
+//
+//	{"argument": "project", "value": "orch", "context": {"other_arg": "..."}}
+//
+// and returns {"values": ["orchestra", ...]}. These tools are hidden from
+// tools/list.
+const completionToolPrefix = "__complete_"
+
+// errUnknownCompletionRef is returned for completion requests whose reference
+// names no known resource template argument.
+var errUnknownCompletionRef = errors.New("unknown completion reference")
+
+// completeParams is the expected shape of params for completion/complete.
+type completeParams struct {
+	Ref struct {
+		Type string `json:"type"`
+		Name string `json:"name"` // ref/prompt
+		URI  string `json:"uri"`  // ref/resource
+	} `json:"ref"`
+	Argument struct {
+		Name  string `json:"name"`
+		Value string `json:"value"`
+	} `json:"argument"`
+	Context struct {
+		Arguments map[string]string `json:"arguments"`
+	} `json:"context"`
+}
+
+// completion is the JSON shape of the completion in a completion/complete
+// response.
+type completion struct {
+	Values  []string `json:"values"`
+	Total   int      `json:"total,omitempty"`
+	HasMore bool     `json:"hasMore,omitempty"`
+}
+
+// completeResult is the JSON shape for a completion/complete response.
+type completeResult struct {
+	Completion completion `json:"completion"`
+}
+
+// handleComplete suggests values for a prompt argument or a resource template
+// variable.
+func (t *StdioTransport) handleComplete(ctx context.Context, req *protocol.JSONRPCRequest) *protocol.JSONRPCResponse {
+	var params completeParams
+	if req.Params != nil {
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return &protocol.JSONRPCResponse{
+				JSONRPC: "2.0",
+				ID:      req.ID,
+				Error: &protocol.JSONRPCError{
+					Code:    protocol.InvalidParams,
+					Message: fmt.Sprintf("invalid params: %v", err),
+				},
+			}
+		}
+	}
+
+	if params.Argument.Name == "" {
+		return &protocol.JSONRPCResponse{
+			JSONRPC: "2.0",
+			ID:      req.ID,
+			Error: &protocol.JSONRPCError{
+				Code:    protocol.InvalidParams,
+				Message: "missing required parameter: argument.name",
+			},
+		}
+	}
+
+	var values []string
+	var err error
+	switch params.Ref.Type {
+	case "ref/resource":
+		values, err = t.completeResourceID(ctx, req.ID, params)
+	case "ref/prompt":
+		values = t.completePromptArgument(ctx, req.ID, params)
+	default:
+		err = fmt.Errorf("%w type %q", errUnknownCompletionRef, params.Ref.Type)
+	}
+	if err != nil {
+		code := protocol.InternalError
+		if errors.Is(err, errUnknownCompletionRef) {
+			code = protocol.InvalidParams
+		}
+		return &protocol.JSONRPCResponse{
+			JSONRPC: "2.0",
+			ID:      req.ID,
+			Error: &protocol.JSONRPCError{
+				Code:    code,
+				Message: err.Error(),
+			},
+		}
+	}
+
+	return &protocol.JSONRPCResponse{
+		JSONRPC: "2.0",
+		ID:      req.ID,
+		Result:  completeResult{Completion: newCompletion(values)},
+	}
+}
+
+// newCompletion truncates values to the MCP limit, reporting the total.
+func newCompletion(values []string) completion {
+	c := completion{Values: values, Total: len(values)}
+	if c.Values == nil {
+		c.Values = []string{}
+	}
+	if len(c.Values) > maxCompletionValues {
+		c.Values = c.Values[:maxCompletionValues]
+		c.HasMore = true
+	}
+	return c
+}
+
+// completeResourceID completes the {id} variable of a resource template such
+// as "orchestra://features/{id}" with the IDs of stored documents that start
+// with the typed value, ignoring case.
+func (t *StdioTransport) completeResourceID(ctx context.Context, id any, params completeParams) ([]string, error) {
+	for _, ns := range t.namespaces {
+		if params.Ref.URI != ns.uriTemplate() || params.Argument.Name != "id" {
+			continue
+		}
+
+		resp, err := t.send(ctx, &pluginv1.PluginRequest{
+			RequestId: t.requestID("cc", id),
+			Request: &pluginv1.PluginRequest_StorageList{
+				StorageList: &pluginv1.StorageListRequest{
+					Prefix: ns.Prefix,
+				},
+			},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("storage list failed: %w", err)
+		}
+
+		prefix := strings.ToLower(params.Argument.Value)
+		var values []string
+		for _, entry := range resp.GetStorageList().GetEntries() {
+			if owner, ok := t.namespaceForPath(entry.GetPath()); !ok || owner.Scheme != ns.Scheme {
+				continue
+			}
+			name, ok := ns.resourceID(entry.GetPath())
+			if ok && strings.HasPrefix(strings.ToLower(name), prefix) {
+				values = append(values, name)
+			}
+		}
+		slices.Sort(values)
+		return values, nil
+	}
+	return nil, fmt.Errorf("%w: %s argument %q", errUnknownCompletionRef, params.Ref.URI, params.Argument.Name)
+}
+
+// completePromptArgument asks the plugin owning a prompt to complete one of
+// its arguments through the prompt's completion tool. Prompts whose plugin has
+// no completion tool get no suggestions without asking the orchestrator to
+// call it, and failed calls get none either.
+func (t *StdioTransport) completePromptArgument(ctx context.Context, id any, params completeParams) []string {
+	toolName := completionToolPrefix + params.Ref.Name
+	lt, err := t.listTools(ctx, id)
+	if err != nil {
+		slog.Debug("listing completion tools failed", "prompt", params.Ref.Name, "error", err)
+		return nil
+	}
+	if !slices.ContainsFunc(lt.GetTools(), func(td *pluginv1.ToolDefinition) bool { return td.GetName() == toolName }) {
+		return nil
+	}
+
+	contextArgs := make(map[string]any, len(params.Context.Arguments))
+	for k, v := range params.Context.Arguments {
+		contextArgs[k] = v
+	}
+	args, err := structpb.NewStruct(map[string]any{
+		"argument": params.Argument.Name,
+		"value":    params.Argument.Value,
+		"context":  contextArgs,
+	})
+	if err != nil {
+		slog.Debug("encoding completion arguments failed", "error", err)
+		return nil
+	}
+
+	resp, err := t.send(ctx, &pluginv1.PluginRequest{
+		RequestId: t.requestID("cp", id),
+		Request: &pluginv1.PluginRequest_ToolCall{
+			ToolCall: &pluginv1.ToolRequest{
+				ToolName:     toolName,
+				Arguments:    args,
+				CallerPlugin: transportPluginID,
+				SessionId:    t.sessionID,
+			},
+		},
+	})
+	tc := resp.GetToolCall()
+	if err != nil || !tc.GetSuccess() {
+		slog.Debug("prompt completion unavailable", "prompt", params.Ref.Name, "error", err, "tool_error", tc.GetErrorMessage())
+		return nil
+	}
+
+	var values []string
+	for _, v := range tc.GetResult().GetFields()["values"].GetListValue().GetValues() {
+		if s := v.GetStringValue(); s != "" {
+			values = append(values, s)
+		}
+	}
+	return values
+}
@@ -0,0 +1,192 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	pluginv1 "github.com/orchestra-mcp/gen-go/orchestra/plugin/v1"
+	"github.com/orchestra-mcp/sdk-go/protocol"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// complete dispatches a completion/complete request with the given params.
+func complete(t *testing.T, tr *StdioTransport, params string) protocol.JSONRPCResponse {
+	t.Helper()
+	return roundTrip(t, tr.dispatch(context.Background(), &protocol.JSONRPCRequest{
+		JSONRPC: "2.0", ID: 1, Method: "completion/complete", Params: json.RawMessage(params),
+	}))
+}
+
+// completionOf extracts the completion from a completion/complete response.
+func completionOf(t *testing.T, resp protocol.JSONRPCResponse) completion {
+	t.Helper()
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %+v", resp.Error)
+	}
+	raw, _ := json.Marshal(resp.Result)
+	var result completeResult
+	if err := json.Unmarshal(raw, &result); err != nil {
+		t.Fatalf("unmarshal completion: %v", err)
+	}
+	return result.Completion
+}
+
+// storageListSender answers StorageList requests for prefix with paths.
+func storageListSender(prefix string, paths ...string) *mockSender {
+	return &mockSender{
+		sendFunc: func(ctx context.Context, req *pluginv1.PluginRequest) (*pluginv1.PluginResponse, error) {
+			list := &pluginv1.StorageListResponse{}
+			if req.GetStorageList().GetPrefix() == prefix {
+				for _, p := range paths {
+					list.Entries = append(list.Entries, &pluginv1.StorageEntry{Path: p})
+				}
+			}
+			return &pluginv1.PluginResponse{
+				RequestId: req.RequestId,
+				Response:  &pluginv1.PluginResponse_StorageList{StorageList: list},
+			}, nil
+		},
+	}
+}
+
+func TestCompleteResourceID(t *testing.T) {
+	sender := storageListSender("features/", "features/FEAT-B2.md", "features/feat-a1.md", "features/BUG-1.md")
+	tr := newReadyTransport(t, sender, nil, nil)
+
+	c := completionOf(t, complete(t, tr, `{"ref":{"type":"ref/resource","uri":"orchestra://features/{id}"},"argument":{"name":"id","value":"FEAT"}}`))
+	if fmt.Sprint(c.Values) != "[FEAT-B2 feat-a1]" || c.Total != 2 || c.HasMore {
+		t.Errorf("unexpected completion: %+v", c)
+	}
+
+	resp := complete(t, tr, `{"ref":{"type":"ref/resource","uri":"orchestra://bugs/{id}"},"argument":{"name":"id","value":""}}`)
+	if resp.Error == nil || resp.Error.Code != protocol.InvalidParams {
+		t.Errorf("expected InvalidParams for unknown template, got %+v", resp)
+	}
+}
+
+func TestCompleteTruncatesValues(t *testing.T) {
+	var paths []string
+	for i := range 150 {
+		paths = append(paths, fmt.Sprintf("notes/n%03d.md", i))
+	}
+	tr := newReadyTransport(t, storageListSender("notes/", paths...), nil, nil)
+
+	c := completionOf(t, complete(t, tr, `{"ref":{"type":"ref/resource","uri":"orchestra://notes/{id}"},"argument":{"name":"id","value":"n"}}`))
+	if len(c.Values) != maxCompletionValues || c.Total != 150 || !c.HasMore {
+		t.Errorf("expected %d of 150 values with hasMore, got %d, total %d, hasMore %v", maxCompletionValues, len(c.Values), c.Total, c.HasMore)
+	}
+}
+
+func TestCompletePromptArgument(t *testing.T) {
+	var got *pluginv1.ToolRequest
+	var toolCalls, toolLists int
+	sender := &mockSender{
+		sendFunc: func(ctx context.Context, req *pluginv1.PluginRequest) (*pluginv1.PluginResponse, error) {
+			if req.GetListTools() != nil {
+				toolLists++
+				return &pluginv1.PluginResponse{
+					Response: &pluginv1.PluginResponse_ListTools{
+						ListTools: &pluginv1.ListToolsResponse{
+							Tools: []*pluginv1.ToolDefinition{{Name: "create_feature"}, {Name: "__complete_plan"}},
+						},
+					},
+				}, nil
+			}
+			toolCalls++
+			got = req.GetToolCall()
+			result, _ := structpb.NewStruct(map[string]any{"values": []any{"orchestra", "orchid"}})
+			return &pluginv1.PluginResponse{
+				Response: &pluginv1.PluginResponse_ToolCall{
+					ToolCall: &pluginv1.ToolResponse{Success: true, Result: result},
+				},
+			}, nil
+		},
+	}
+	tr := newReadyTransport(t, sender, nil, nil, withEvents())
+
+	c := completionOf(t, complete(t, tr, `{"ref":{"type":"ref/prompt","name":"plan"},"argument":{"name":"project","value":"orch"},"context":{"arguments":{"owner":"me"}}}`))
+	if fmt.Sprint(c.Values) != "[orchestra orchid]" {
+		t.Errorf("unexpected completion: %+v", c)
+	}
+	if got.GetToolName() != "__complete_plan" {
+		t.Errorf("unexpected completion tool: %q", got.GetToolName())
+	}
+	args := got.GetArguments().AsMap()
+	if args["argument"] != "project" || args["value"] != "orch" || args["context"].(map[string]any)["owner"] != "me" {
+		t.Errorf("unexpected completion tool arguments: %v", args)
+	}
+
+	// Prompts without a completion tool complete to nothing, without a call
+	// to the orchestrator.
+	c = completionOf(t, complete(t, tr, `{"ref":{"type":"ref/prompt","name":"review"},"argument":{"name":"file","value":""}}`))
+	if len(c.Values) != 0 || c.HasMore {
+		t.Errorf("expected no values, got %+v", c)
+	}
+	if toolCalls != 1 || toolLists != 1 {
+		t.Errorf("expected one tool call and one cached tool list, got %d and %d", toolCalls, toolLists)
+	}
+}
+
+func TestCompleteInvalidParams(t *testing.T) {
+	tr := newReadyTransport(t, &mockSender{}, nil, nil)
+	for _, params := range []string{
+		`{"ref":{"type":"ref/prompt","name":"plan"}}`,
+		`{"ref":{"type":"ref/tool","name":"x"},"argument":{"name":"a","value":""}}`,
+	} {
+		resp := complete(t, tr, params)
+		if resp.Error == nil || resp.Error.Code != protocol.InvalidParams {
+			t.Errorf("%s: expected InvalidParams, got %+v", params, resp)
+		}
+	}
+}
+
+func TestCompletionsCapability(t *testing.T) {
+	for version, want := range map[string]bool{"2025-06-18": true, "2025-03-26": true, "2024-11-05": false} {
+		resp := initializeWith(t, NewStdioTransport(&mockSender{}, nil, nil), version)
+		caps := resp.Result.(map[string]any)["capabilities"].(map[string]any)
+		if _, ok := caps["completions"]; ok != want {
+			t.Errorf("%s: completions capability advertised %v, want %v", version, ok, want)
+		}
+	}
+}
+
+func TestToolsListHidesCompletionTools(t *testing.T) {
+	sender := &mockSender{
+		sendFunc: func(ctx context.Context, req *pluginv1.PluginRequest) (*pluginv1.PluginResponse, error) {
+			return &pluginv1.PluginResponse{
+				Response: &pluginv1.PluginResponse_ListTools{
+					ListTools: &pluginv1.ListToolsResponse{
+						Tools: []*pluginv1.ToolDefinition{{Name: "create_feature"}, {Name: "__complete_plan"}},
+					},
+				},
+			}, nil
+		},
+	}
+	tr := newReadyTransport(t, sender, nil, nil)
+
+	resp := roundTrip(t, tr.dispatch(context.Background(), &protocol.JSONRPCRequest{JSONRPC: "2.0", ID: 1, Method: "tools/list"}))
+	tools := resp.Result.(map[string]any)["tools"].([]any)
+	if len(tools) != 1 || tools[0].(map[string]any)["name"] != "create_feature" {
+		t.Errorf("expected only create_feature, got %v", tools)
+	}
+}
+
+func TestToolsCallRejectsCompletionTools(t *testing.T) {
+	sender := &mockSender{
+		sendFunc: func(ctx context.Context, req *pluginv1.PluginRequest) (*pluginv1.PluginResponse, error) {
+			t.Errorf("unexpected orchestrator request for %s", req.GetToolCall().GetToolName())
+			return nil, errors.New("unexpected request")
+		},
+	}
+	tr := newReadyTransport(t, sender, nil, nil)
+
+	resp := tr.dispatch(context.Background(), &protocol.JSONRPCRequest{
+		JSONRPC: "2.0", ID: 1, Method: "tools/call", Params: json.RawMessage(`{"name":"__complete_plan","arguments":{"argument":"x"}}`),
+	})
+	if resp.Error == nil || resp.Error.Code != protocol.InvalidParams {
+		t.Errorf("expected InvalidParams, got %+v", resp)
+	}
+}
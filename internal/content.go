@@ -0,0 +1,159 @@
+package internal
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// ToolResult is the MCP result of a tools/call. It mirrors
+// protocol.MCPToolResult with the content block types the SDK does not model
+// yet.
+type ToolResult struct {
+	Content           []ContentBlock `json:"content"`
+	StructuredContent map[string]any `json:"structuredContent,omitempty"`
+	IsError           bool           `json:"isError,omitempty"`
+}
+
+// ContentBlock is one MCP content block. Type selects which fields are used:
+//
+//	text:          Text
+//	image, audio:  Data (base64) and MimeType
+//	resource:      Resource
+//	resource_link: URI, Name, MimeType and Description
+type ContentBlock struct {
+	Type        string
+	Text        string
+	Data        string
+	MimeType    string
+	Resource    *ResourceContents
+	URI         string
+	Name        string
+	Description string
+}
+
+// ResourceContents is the content of a resource, either Text or base64 Blob.
+type ResourceContents struct {
+	URI      string `json:"uri"`
+	MimeType string `json:"mimeType,omitempty"`
+	Text     string `json:"text,omitempty"`
+	Blob     string `json:"blob,omitempty"`
+}
+
+// MarshalJSON encodes only the fields that belong to the block's type, so a
+// text block always carries "text" even when it is empty and other blocks do
+// not.
+func (c ContentBlock) MarshalJSON() ([]byte, error) {
+	switch c.Type {
+	case "image", "audio":
+		return json.Marshal(struct {
+			Type     string `json:"type"`
+			Data     string `json:"data"`
+			MimeType string `json:"mimeType"`
+		}{c.Type, c.Data, c.MimeType})
+	case "resource":
+		return json.Marshal(struct {
+			Type     string            `json:"type"`
+			Resource *ResourceContents `json:"resource"`
+		}{c.Type, c.Resource})
+	case "resource_link":
+		return json.Marshal(struct {
+			Type        string `json:"type"`
+			URI         string `json:"uri"`
+			Name        string `json:"name"`
+			MimeType    string `json:"mimeType,omitempty"`
+			Description string `json:"description,omitempty"`
+		}{c.Type, c.URI, c.Name, c.MimeType, c.Description})
+	default:
+		return json.Marshal(struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		}{c.Type, c.Text})
+	}
+}
+
+// resultContent decodes the rich content convention of a tool result: a
+// "content" list of MCP content blocks, e.g.
+//
+//	{"content": [
+//	  {"type": "text", "text": "Rendered diagram:"},
+//	  {"type": "image", "data": "iVBORw0KGgo...", "mimeType": "image/png"}
+//	]}
+//
+// It reports false if the result has no "content" list or any block in it is
+// invalid, in which case the caller falls back to plain text.
+func resultContent(s *structpb.Struct) ([]ContentBlock, bool) {
+	list := s.GetFields()["content"].GetListValue()
+	if list == nil || len(list.GetValues()) == 0 {
+		return nil, false
+	}
+
+	blocks := make([]ContentBlock, 0, len(list.GetValues()))
+	for _, v := range list.GetValues() {
+		block, err := contentBlockFromStruct(v.GetStructValue())
+		if err != nil {
+			slog.Debug("tool result content not usable, falling back to text", "error", err)
+			return nil, false
+		}
+		blocks = append(blocks, block)
+	}
+	return blocks, true
+}
+
+// contentBlockFromStruct converts one element of a "content" list, checking
+// the fields its type requires.
+func contentBlockFromStruct(s *structpb.Struct) (ContentBlock, error) {
+	if s == nil {
+		return ContentBlock{}, fmt.Errorf("content block is not an object")
+	}
+	f := s.GetFields()
+	str := func(key string) string { return f[key].GetStringValue() }
+
+	block := ContentBlock{Type: str("type")}
+	switch block.Type {
+	case "text":
+		if _, ok := f["text"]; !ok {
+			return ContentBlock{}, fmt.Errorf("text block missing text")
+		}
+		block.Text = str("text")
+	case "image", "audio":
+		block.Data, block.MimeType = str("data"), str("mimeType")
+		if block.MimeType == "" {
+			return ContentBlock{}, fmt.Errorf("%s block missing mimeType", block.Type)
+		}
+		if _, err := base64.StdEncoding.DecodeString(block.Data); err != nil || block.Data == "" {
+			return ContentBlock{}, fmt.Errorf("%s block data is not base64", block.Type)
+		}
+	case "resource":
+		r := f["resource"].GetStructValue().GetFields()
+		block.Resource = &ResourceContents{
+			URI:      r["uri"].GetStringValue(),
+			MimeType: r["mimeType"].GetStringValue(),
+			Text:     r["text"].GetStringValue(),
+			Blob:     r["blob"].GetStringValue(),
+		}
+		if block.Resource.URI == "" {
+			return ContentBlock{}, fmt.Errorf("resource block missing resource.uri")
+		}
+		if block.Resource.Blob != "" {
+			if _, err := base64.StdEncoding.DecodeString(block.Resource.Blob); err != nil {
+				return ContentBlock{}, fmt.Errorf("resource block blob is not base64")
+			}
+		}
+	case "resource_link":
+		block.URI, block.Name = str("uri"), str("name")
+		block.MimeType, block.Description = str("mimeType"), str("description")
+		if block.URI == "" {
+			return ContentBlock{}, fmt.Errorf("resource_link block missing uri")
+		}
+		if block.Name == "" {
+			block.Name = block.URI
+		}
+	default:
+		return ContentBlock{}, fmt.Errorf("unknown content type %q", block.Type)
+	}
+	return block, nil
+}
@@ -0,0 +1,90 @@
+package internal
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	pluginv1 "github.com/orchestra-mcp/gen-go/orchestra/plugin/v1"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestToolResponseToMCPRichContent(t *testing.T) {
+	result, err := structpb.NewStruct(map[string]any{
+		"content": []any{
+			map[string]any{"type": "text", "text": "Rendered diagram:"},
+			map[string]any{"type": "image", "data": "iVBORw0KGgo=", "mimeType": "image/png"},
+			map[string]any{"type": "audio", "data": "UklGRg==", "mimeType": "audio/wav"},
+			map[string]any{"type": "resource", "resource": map[string]any{
+				"uri": "orchestra://notes/diagram.md", "mimeType": "text/markdown", "text": "# Diagram",
+			}},
+			map[string]any{"type": "resource_link", "uri": "orchestra://notes/full.md", "name": "full.md", "mimeType": "text/markdown"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewStruct: %v", err)
+	}
+
+	mcp := ToolResponseToMCP(&pluginv1.ToolResponse{Success: true, Result: result})
+	if len(mcp.Content) != 5 {
+		t.Fatalf("expected 5 content blocks, got %d", len(mcp.Content))
+	}
+
+	raw, err := json.Marshal(mcp)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	var decoded struct {
+		Content []map[string]any `json:"content"`
+	}
+	if err := json.Unmarshal(raw, &decoded); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+
+	want := []map[string]any{
+		{"type": "text", "text": "Rendered diagram:"},
+		{"type": "image", "data": "iVBORw0KGgo=", "mimeType": "image/png"},
+		{"type": "audio", "data": "UklGRg==", "mimeType": "audio/wav"},
+		{"type": "resource", "resource": map[string]any{
+			"uri": "orchestra://notes/diagram.md", "mimeType": "text/markdown", "text": "# Diagram",
+		}},
+		{"type": "resource_link", "uri": "orchestra://notes/full.md", "name": "full.md", "mimeType": "text/markdown"},
+	}
+	for i, w := range want {
+		got, _ := json.Marshal(decoded.Content[i])
+		exp, _ := json.Marshal(w)
+		if string(got) != string(exp) {
+			t.Errorf("block %d:\n got %s\nwant %s", i, got, exp)
+		}
+	}
+}
+
+func TestToolResponseToMCPInvalidContentFallsBack(t *testing.T) {
+	tests := map[string]any{
+		"unknown type":    map[string]any{"type": "video", "data": "AAAA"},
+		"bad base64":      map[string]any{"type": "image", "data": "not base64!", "mimeType": "image/png"},
+		"missing mime":    map[string]any{"type": "image", "data": "AAAA"},
+		"link no uri":     map[string]any{"type": "resource_link", "name": "x"},
+		"not an object":   "plain string",
+		"resource no uri": map[string]any{"type": "resource", "resource": map[string]any{"text": "x"}},
+	}
+	for name, block := range tests {
+		t.Run(name, func(t *testing.T) {
+			result, _ := structpb.NewStruct(map[string]any{"content": []any{block}})
+			mcp := ToolResponseToMCP(&pluginv1.ToolResponse{Success: true, Result: result})
+			if len(mcp.Content) != 1 || mcp.Content[0].Type != "text" {
+				t.Fatalf("expected a single text block, got %+v", mcp.Content)
+			}
+			if !strings.Contains(mcp.Content[0].Text, `"content"`) {
+				t.Errorf("expected JSON fallback of the whole result, got %q", mcp.Content[0].Text)
+			}
+		})
+	}
+}
+
+func TestContentBlockTextAlwaysHasText(t *testing.T) {
+	raw, _ := json.Marshal(ContentBlock{Type: "text"})
+	if string(raw) != `{"type":"text","text":""}` {
+		t.Errorf("got %s", raw)
+	}
+}
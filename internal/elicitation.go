@@ -0,0 +1,111 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// elicitationRequestTopic is the orchestrator event topic on which plugins ask
+// the user for input through the client's elicitation/create, typically while
+// one of their tools is running. The payload has the same shape as a sampling
+// request; params is an MCP elicitation request:
+//
+//	{"request_id": "perm-7", "session_id": "5f0c...",
+//	 "params": {"message": "Allow deleting FEAT-ABC?",
+//	            "requestedSchema": {"type": "object",
+//	              "properties": {"confirm": {"type": "boolean"}}, "required": ["confirm"]}}}
+//
+// The outcome is published on elicitationResultTopic.
+const elicitationRequestTopic = "client.elicitation.request"
+
+// elicitationResultTopic is the topic on which the transport publishes the
+// outcome of an elicitation request. The result holds the user's action and,
+// for "accept", the content, already checked against the requested schema:
+//
+//	{"request_id": "perm-7", "plugin": "tools.features",
+//	 "result": {"action": "accept", "content": {"confirm": true}}}
+//	{"request_id": "perm-7", "plugin": "tools.features", "result": {"action": "decline"}}
+//	{"request_id": "perm-7", "plugin": "tools.features", "error": "..."}
+const elicitationResultTopic = "client.elicitation.result"
+
+// errElicitationUnsupported is returned for elicitation requests on sessions
+// whose client did not advertise elicitation or negotiated a protocol version
+// without it.
+var errElicitationUnsupported = errors.New("client does not support elicitation")
+
+// elicitationPropertyTypes are the property types an elicitation schema may
+// use. MCP restricts requested schemas to flat objects of primitives.
+var elicitationPropertyTypes = []string{"string", "number", "integer", "boolean"}
+
+// elicit asks the user for input through the client and returns the result
+// as published to the plugin.
+func (t *StdioTransport) elicit(ctx context.Context, params *structpb.Struct) (map[string]any, error) {
+	// Capabilities are only read once the handshake has completed.
+	if t.State() != StateReady || !t.supports(featureElicitation) || t.clientCaps.Elicitation == nil {
+		return nil, errElicitationUnsupported
+	}
+	if params == nil {
+		return nil, fmt.Errorf("elicitation request missing params")
+	}
+	p := params.AsMap()
+	if msg, _ := p["message"].(string); msg == "" {
+		return nil, fmt.Errorf("invalid elicitation request: missing message")
+	}
+	schema, _ := p["requestedSchema"].(map[string]any)
+	if err := checkElicitationSchema(schema); err != nil {
+		return nil, fmt.Errorf("invalid elicitation request: %w", err)
+	}
+
+	raw, err := t.requestClient(ctx, "elicitation/create", p)
+	if err != nil {
+		return nil, err
+	}
+	var result struct {
+		Action  string         `json:"action"`
+		Content map[string]any `json:"content"`
+	}
+	if err := json.Unmarshal(raw, &result); err != nil {
+		return nil, fmt.Errorf("invalid elicitation/create result: %w", err)
+	}
+
+	switch result.Action {
+	case "accept":
+		if result.Content == nil {
+			result.Content = map[string]any{}
+		}
+		if err := validateSchema(schema, result.Content); err != nil {
+			return nil, fmt.Errorf("elicitation response does not match requestedSchema: %w", err)
+		}
+		return map[string]any{"action": result.Action, "content": result.Content}, nil
+	case "decline", "cancel":
+		return map[string]any{"action": result.Action}, nil
+	default:
+		return nil, fmt.Errorf("invalid elicitation/create result: unknown action %q", result.Action)
+	}
+}
+
+// checkElicitationSchema checks that a requested schema is a flat object whose
+// properties are primitives, as MCP requires.
+func checkElicitationSchema(schema map[string]any) error {
+	if schema == nil {
+		return fmt.Errorf("missing requestedSchema")
+	}
+	if schema["type"] != "object" {
+		return fmt.Errorf("requestedSchema must have type object")
+	}
+	props, _ := schema["properties"].(map[string]any)
+	for _, name := range slices.Sorted(maps.Keys(props)) {
+		prop, _ := props[name].(map[string]any)
+		typ, _ := prop["type"].(string)
+		if !slices.Contains(elicitationPropertyTypes, typ) {
+			return fmt.Errorf("property %q must have one of the types %v", name, elicitationPropertyTypes)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package internal
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	pluginv1 "github.com/orchestra-mcp/gen-go/orchestra/plugin/v1"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func elicitationEvent(t *testing.T, params map[string]any) *pluginv1.EventDelivery {
+	t.Helper()
+	s, err := structpb.NewStruct(map[string]any{"request_id": "perm-1", "params": params})
+	if err != nil {
+		t.Fatalf("elicitation payload: %v", err)
+	}
+	return &pluginv1.EventDelivery{Topic: elicitationRequestTopic, SourcePlugin: "tools.features", Payload: s}
+}
+
+var confirmParams = map[string]any{
+	"message": "Allow deleting FEAT-ABC?",
+	"requestedSchema": map[string]any{
+		"type":       "object",
+		"properties": map[string]any{"confirm": map[string]any{"type": "boolean"}},
+		"required":   []any{"confirm"},
+	},
+}
+
+// elicitAndAnswer sends an elicitation request, answers it with result and
+// returns the published reply.
+func elicitAndAnswer(t *testing.T, result string) map[string]any {
+	t.Helper()
+	sender, published := publishRecorder()
+	s := startClientSession(t, sender, `{"elicitation":{}}`)
+
+	s.events <- elicitationEvent(t, confirmParams)
+	var req clientRequest
+	if err := json.Unmarshal([]byte(s.out.next(t)), &req); err != nil {
+		t.Fatalf("parse request: %v", err)
+	}
+	if req.Method != "elicitation/create" {
+		t.Fatalf("expected elicitation/create, got %+v", req)
+	}
+	s.send(t, `{"jsonrpc":"2.0","id":"`+req.ID+`","result":`+result+`}`)
+	return waitPublished(t, published)
+}
+
+func TestElicitationActions(t *testing.T) {
+	tests := []struct {
+		name, result string
+		wantAction   string
+		wantErr      string
+	}{
+		{"accept", `{"action":"accept","content":{"confirm":true}}`, "accept", ""},
+		{"decline drops content", `{"action":"decline","content":{"confirm":true}}`, "decline", ""},
+		{"cancel", `{"action":"cancel"}`, "cancel", ""},
+		{"invalid content", `{"action":"accept","content":{"confirm":"yes"}}`, "", "does not match requestedSchema"},
+		{"missing content", `{"action":"accept"}`, "", `missing required property "confirm"`},
+		{"unknown action", `{"action":"maybe"}`, "", "unknown action"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reply := elicitAndAnswer(t, tt.result)
+			if tt.wantErr != "" {
+				if msg, _ := reply["error"].(string); !strings.Contains(msg, tt.wantErr) {
+					t.Errorf("expected error containing %q, got %v", tt.wantErr, reply)
+				}
+				return
+			}
+			result, _ := reply["result"].(map[string]any)
+			if result["action"] != tt.wantAction {
+				t.Errorf("action: got %v, want %s", reply, tt.wantAction)
+			}
+			if _, ok := result["content"]; ok != (tt.wantAction == "accept") {
+				t.Errorf("content present %v for action %s", ok, tt.wantAction)
+			}
+		})
+	}
+}
+
+func TestElicitationRejectsNestedSchema(t *testing.T) {
+	sender, published := publishRecorder()
+	s := startClientSession(t, sender, `{"elicitation":{}}`)
+
+	s.events <- elicitationEvent(t, map[string]any{
+		"message": "Pick",
+		"requestedSchema": map[string]any{
+			"type":       "object",
+			"properties": map[string]any{"nested": map[string]any{"type": "object"}},
+		},
+	})
+	if msg, _ := waitPublished(t, published)["error"].(string); !strings.Contains(msg, `property "nested"`) {
+		t.Errorf("expected schema error, got %q", msg)
+	}
+}
+
+func TestElicitationRequiresCapabilityAndVersion(t *testing.T) {
+	sender, published := publishRecorder()
+	s := startClientSession(t, sender, `{}`)
+	s.events <- elicitationEvent(t, confirmParams)
+	if reply := waitPublished(t, published); reply["error"] != errElicitationUnsupported.Error() {
+		t.Errorf("without capability: got %v", reply)
+	}
+
+	sender, published = publishRecorder()
+	s = startClientSession(t, sender, `{"elicitation":{}}`)
+	s.tr.protocolVersion = protocolVersion20250326
+	s.events <- elicitationEvent(t, confirmParams)
+	if reply := waitPublished(t, published); reply["error"] != errElicitationUnsupported.Error() {
+		t.Errorf("with 2025-03-26: got %v", reply)
+	}
+}
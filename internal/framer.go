@@ -0,0 +1,89 @@
+package internal
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const (
+	// defaultMaxMessageSize bounds a single JSON-RPC message unless changed
+	// with WithMaxMessageSize.
+	defaultMaxMessageSize = 10 * 1024 * 1024
+
+	// frameReadSize is the read buffer size. Messages that fit are returned
+	// straight from it without copying.
+	frameReadSize = 64 * 1024
+
+	// maxRetainedFrame caps the capacity kept between messages after an
+	// unusually large one has been assembled.
+	maxRetainedFrame = 1024 * 1024
+)
+
+// errFrameTooLarge is returned by lineFramer.next for a message exceeding the
+// size limit. The message is skipped and reading can continue.
+var errFrameTooLarge = errors.New("message too large")
+
+// lineFramer splits newline-delimited JSON-RPC messages from a stream. Unlike
+// bufio.Scanner it has no fixed maximum line length: lines longer than the
+// read buffer are assembled in a growable buffer, subject to an optional size
+// limit. An oversized line is discarded up to its newline so that one bad
+// message does not end the session.
+type lineFramer struct {
+	r   *bufio.Reader
+	max int // maximum message size in bytes; 0 means unlimited
+	buf []byte
+}
+
+func newLineFramer(r io.Reader, max int) *lineFramer {
+	return &lineFramer{r: bufio.NewReaderSize(r, frameReadSize), max: max}
+}
+
+// next returns the next line with surrounding whitespace trimmed. The slice is
+// only valid until the following call. It returns errFrameTooLarge for an
+// oversized line and io.EOF once the input is exhausted.
+func (f *lineFramer) next() ([]byte, error) {
+	if cap(f.buf) > maxRetainedFrame {
+		f.buf = nil
+	}
+	f.buf = f.buf[:0]
+	size := 0
+
+	for {
+		chunk, err := f.r.ReadSlice('\n')
+		size += len(chunk)
+		tooLarge := f.max > 0 && size-newlineLen(chunk) > f.max
+
+		switch {
+		case err == nil && len(f.buf) == 0 && !tooLarge:
+			// The whole line is in the read buffer; hand it out directly.
+			return bytes.TrimSpace(chunk), nil
+		case !tooLarge:
+			f.buf = append(f.buf, chunk...)
+		default:
+			f.buf = f.buf[:0]
+		}
+
+		switch {
+		case err == nil || (err == io.EOF && size > 0):
+			if tooLarge {
+				return nil, fmt.Errorf("%w: exceeds %d bytes", errFrameTooLarge, f.max)
+			}
+			return bytes.TrimSpace(f.buf), nil
+		case errors.Is(err, bufio.ErrBufferFull):
+			continue
+		default:
+			return nil, err
+		}
+	}
+}
+
+// newlineLen reports how many bytes of a chunk are its trailing newline.
+func newlineLen(chunk []byte) int {
+	if len(chunk) > 0 && chunk[len(chunk)-1] == '\n' {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,66 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/orchestra-mcp/sdk-go/protocol"
+)
+
+func TestLineFramer(t *testing.T) {
+	big := strings.Repeat("x", 3*frameReadSize+17)
+	input := "  first  \n\n" + big + "\r\nlast"
+	f := newLineFramer(strings.NewReader(input), 0)
+
+	for _, want := range []string{"first", "", big, "last"} {
+		got, err := f.next()
+		if err != nil {
+			t.Fatalf("next: %v", err)
+		}
+		if string(got) != want {
+			t.Fatalf("frame: got %d bytes, want %d bytes", len(got), len(want))
+		}
+	}
+	if _, err := f.next(); !errors.Is(err, io.EOF) {
+		t.Errorf("expected io.EOF, got %v", err)
+	}
+}
+
+func TestLineFramerTooLarge(t *testing.T) {
+	input := strings.Repeat("y", 2*frameReadSize) + "\n" + strings.Repeat("z", 10) + "\n"
+	f := newLineFramer(strings.NewReader(input), 10)
+
+	if _, err := f.next(); !errors.Is(err, errFrameTooLarge) {
+		t.Fatalf("expected errFrameTooLarge, got %v", err)
+	}
+	got, err := f.next()
+	if err != nil || string(got) != strings.Repeat("z", 10) {
+		t.Fatalf("frame after oversized one: got %q, %v", got, err)
+	}
+}
+
+func TestOversizedMessageKeepsSession(t *testing.T) {
+	input := `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"` + strings.Repeat("a", 200) + `"}}` + "\n" +
+		`{"jsonrpc":"2.0","id":2,"method":"ping"}` + "\n"
+	var out bytes.Buffer
+	tr := newReadyTransport(t, &mockSender{}, strings.NewReader(input), &out, WithMaxMessageSize(100))
+	if err := tr.Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 responses, got %d: %s", len(lines), out.String())
+	}
+	first := parseJSONRPCResponse(t, lines[0])
+	if first.Error == nil || first.Error.Code != protocol.InvalidRequest || !strings.Contains(first.Error.Message, "exceeds 100 bytes") {
+		t.Errorf("expected oversized message error, got %+v", first.Error)
+	}
+	if second := parseJSONRPCResponse(t, lines[1]); second.Error != nil {
+		t.Errorf("expected ping to succeed after oversized message, got %+v", second.Error)
+	}
+}
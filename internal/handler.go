@@ -2,9 +2,14 @@ package internal
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/google/uuid"
 	pluginv1 "github.com/orchestra-mcp/gen-go/orchestra/plugin/v1"
@@ -12,25 +17,84 @@ import (
 	"google.golang.org/protobuf/types/known/structpb"
 )
 
-// handleInitialize responds to the MCP initialize handshake with the server's
-// protocol version and capabilities. No orchestrator communication is needed.
+// initializeResult is the JSON shape for an initialize response. It mirrors
+// protocol.MCPInitializeResult with capabilities the SDK does not model yet.
+type initializeResult struct {
+	ProtocolVersion string                 `json:"protocolVersion"`
+	Capabilities    serverCapabilities     `json:"capabilities"`
+	ServerInfo      protocol.MCPServerInfo `json:"serverInfo"`
+	SessionID       string                 `json:"_sessionId,omitempty"`
+}
+
+// serverCapabilities is the JSON shape of the capabilities advertised in the
+// initialize response.
+type serverCapabilities struct {
+	Tools       *protocol.MCPToolsCapability   `json:"tools,omitempty"`
+	Prompts     *protocol.MCPPromptsCapability `json:"prompts,omitempty"`
+	Logging     *protocol.MCPLoggingCapability `json:"logging,omitempty"`
+	Resources   *resourcesCapability           `json:"resources,omitempty"`
+	Completions *struct{}                      `json:"completions,omitempty"`
+}
+
+// resourcesCapability describes resource-related capabilities.
+type resourcesCapability struct {
+	Subscribe   bool `json:"subscribe,omitempty"`
+	ListChanged bool `json:"listChanged,omitempty"`
+}
+
+// handleInitialize responds to the MCP initialize handshake with the
+// negotiated protocol version and the server's capabilities, and records the
+// client's info and capabilities for the session. No orchestrator
+// communication is needed.
 func (t *StdioTransport) handleInitialize(req *protocol.JSONRPCRequest) *protocol.JSONRPCResponse {
+	if t.State() != StateUninitialized {
+		return alreadyInitialized(req)
+	}
+	params, version, errResp := parseInitializeParams(req)
+	if errResp != nil {
+		return errResp
+	}
+	if !t.transition(StateUninitialized, StateInitializing) {
+		return alreadyInitialized(req)
+	}
+	t.protocolVersion = version
+	t.clientInfo = params.ClientInfo
+	t.clientCaps = params.Capabilities
+
 	// Generate a unique session ID for this connection.
 	t.sessionID = uuid.New().String()
 
+	caps := serverCapabilities{
+		Tools:     &protocol.MCPToolsCapability{ListChanged: true},
+		Prompts:   &protocol.MCPPromptsCapability{ListChanged: true},
+		Logging:   &protocol.MCPLoggingCapability{},
+		Resources: &resourcesCapability{Subscribe: true, ListChanged: true},
+	}
+	if t.supports(featureCompletions) {
+		caps.Completions = &struct{}{}
+	}
+
 	return &protocol.JSONRPCResponse{
 		JSONRPC: "2.0",
 		ID:      req.ID,
-		Result: protocol.MCPInitializeResult{
-			ProtocolVersion: protocol.MCPProtocolVersion,
-			Capabilities: protocol.MCPServerCapabilities{
-				Tools:     &protocol.MCPToolsCapability{ListChanged: true},
-				Prompts:   &protocol.MCPPromptsCapability{},
-				Logging:   &protocol.MCPLoggingCapability{},
-				Resources: &protocol.MCPResourcesCapability{},
-			},
-			ServerInfo: t.effectiveServerInfo(),
-			SessionID: t.sessionID,
+		Result: initializeResult{
+			ProtocolVersion: version,
+			Capabilities:    caps,
+			ServerInfo:      t.effectiveServerInfo(),
+			SessionID:       t.sessionID,
+		},
+	}
+}
+
+// alreadyInitialized is the response to an initialize request on a session
+// that has already been initialized.
+func alreadyInitialized(req *protocol.JSONRPCRequest) *protocol.JSONRPCResponse {
+	return &protocol.JSONRPCResponse{
+		JSONRPC: "2.0",
+		ID:      req.ID,
+		Error: &protocol.JSONRPCError{
+			Code:    protocol.InvalidRequest,
+			Message: "invalid request: session already initialized",
 		},
 	}
 }
@@ -59,18 +123,50 @@ func (t *StdioTransport) handlePing(req *protocol.JSONRPCRequest) *protocol.JSON
 
 // toolsListResult is the JSON shape for a tools/list response.
 type toolsListResult struct {
-	Tools []protocol.MCPToolDefinition `json:"tools"`
+	Tools      []ToolDefinition `json:"tools"`
+	NextCursor string           `json:"nextCursor,omitempty"`
 }
 
-// handleToolsList queries the orchestrator for all registered tools and converts
-// them to MCP format.
+// listCursor decodes the optional pagination cursor from the params of a list
+// request. On failure it returns the InvalidParams response to send instead.
+func (t *StdioTransport) listCursor(req *protocol.JSONRPCRequest) (pageCursor, *protocol.JSONRPCResponse) {
+	var params listParams
+	if req.Params != nil {
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return pageCursor{}, &protocol.JSONRPCResponse{
+				JSONRPC: "2.0",
+				ID:      req.ID,
+				Error: &protocol.JSONRPCError{
+					Code:    protocol.InvalidParams,
+					Message: fmt.Sprintf("invalid params: %v", err),
+				},
+			}
+		}
+	}
+
+	cursor, err := t.decodeCursor(req.Method, params.Cursor)
+	if err != nil {
+		return pageCursor{}, &protocol.JSONRPCResponse{
+			JSONRPC: "2.0",
+			ID:      req.ID,
+			Error: &protocol.JSONRPCError{
+				Code:    protocol.InvalidParams,
+				Message: err.Error(),
+			},
+		}
+	}
+	return cursor, nil
+}
+
+// handleToolsList queries the orchestrator for all registered tools, or uses
+// the cached list, and converts them to MCP format, one page at a time.
 func (t *StdioTransport) handleToolsList(ctx context.Context, req *protocol.JSONRPCRequest) *protocol.JSONRPCResponse {
-	resp, err := t.sender.Send(ctx, &pluginv1.PluginRequest{
-		RequestId: fmt.Sprintf("stdio-lt-%v", req.ID),
-		Request: &pluginv1.PluginRequest_ListTools{
-			ListTools: &pluginv1.ListToolsRequest{},
-		},
-	})
+	cursor, errResp := t.listCursor(req)
+	if errResp != nil {
+		return errResp
+	}
+
+	lt, err := t.listTools(ctx, req.ID)
 	if err != nil {
 		return &protocol.JSONRPCResponse{
 			JSONRPC: "2.0",
@@ -82,7 +178,6 @@ func (t *StdioTransport) handleToolsList(ctx context.Context, req *protocol.JSON
 		}
 	}
 
-	lt := resp.GetListTools()
 	if lt == nil {
 		return &protocol.JSONRPCResponse{
 			JSONRPC: "2.0",
@@ -94,15 +189,39 @@ func (t *StdioTransport) handleToolsList(ctx context.Context, req *protocol.JSON
 		}
 	}
 
-	mcpTools := make([]protocol.MCPToolDefinition, 0, len(lt.Tools))
-	for _, td := range lt.Tools {
-		mcpTools = append(mcpTools, ToolDefinitionToMCP(td))
+	// Prompt completion tools are internal to the transport.
+	tools := slices.DeleteFunc(slices.Clone(lt.Tools), func(td *pluginv1.ToolDefinition) bool {
+		return strings.HasPrefix(td.GetName(), completionToolPrefix)
+	})
+
+	defs := make([]ToolDefinition, len(tools))
+	keys := make([]string, len(tools))
+	for i, td := range tools {
+		defs[i] = ToolDefinitionToMCP(td)
+		t.applyToolOverride(&defs[i])
+		keys[i] = td.GetName()
+	}
+	t.rememberOutputSchemas(defs)
+	for i := range defs {
+		defs[i] = t.gateToolDefinition(defs[i])
+	}
+
+	start, end, next, err := t.page(cursor, keys)
+	if err != nil {
+		return &protocol.JSONRPCResponse{
+			JSONRPC: "2.0",
+			ID:      req.ID,
+			Error: &protocol.JSONRPCError{
+				Code:    protocol.InvalidParams,
+				Message: err.Error(),
+			},
+		}
 	}
 
 	return &protocol.JSONRPCResponse{
 		JSONRPC: "2.0",
 		ID:      req.ID,
-		Result:  toolsListResult{Tools: mcpTools},
+		Result:  toolsListResult{Tools: defs[start:end], NextCursor: next},
 	}
 }
 
@@ -110,6 +229,7 @@ func (t *StdioTransport) handleToolsList(ctx context.Context, req *protocol.JSON
 type toolCallParams struct {
 	Name      string         `json:"name"`
 	Arguments map[string]any `json:"arguments,omitempty"`
+	Meta      *requestMeta   `json:"_meta,omitempty"`
 }
 
 // handleToolsCall parses the tool name and arguments from the JSON-RPC request,
@@ -140,6 +260,18 @@ func (t *StdioTransport) handleToolsCall(ctx context.Context, req *protocol.JSON
 		}
 	}
 
+	// Prompt completion tools are internal to the transport, as in tools/list.
+	if strings.HasPrefix(params.Name, completionToolPrefix) {
+		return &protocol.JSONRPCResponse{
+			JSONRPC: "2.0",
+			ID:      req.ID,
+			Error: &protocol.JSONRPCError{
+				Code:    protocol.InvalidParams,
+				Message: fmt.Sprintf("unknown tool: %s", params.Name),
+			},
+		}
+	}
+
 	// Convert arguments map to protobuf Struct.
 	var args *structpb.Struct
 	if params.Arguments != nil {
@@ -157,8 +289,36 @@ func (t *StdioTransport) handleToolsCall(ctx context.Context, req *protocol.JSON
 		}
 	}
 
-	resp, err := t.sender.Send(ctx, &pluginv1.PluginRequest{
-		RequestId: fmt.Sprintf("stdio-tc-%v", req.ID),
+	// Wait for a slot under the configured concurrency limits.
+	if t.limiter != nil {
+		release, err := t.limiter.acquire(ctx, params.Name)
+		if err != nil {
+			code := protocol.InternalError
+			if errors.Is(err, errCallQueueFull) {
+				code = codeServerBusy
+			}
+			return &protocol.JSONRPCResponse{
+				JSONRPC: "2.0",
+				ID:      req.ID,
+				Error: &protocol.JSONRPCError{
+					Code:    code,
+					Message: err.Error(),
+				},
+			}
+		}
+		defer release()
+	}
+
+	// Remember the client's progress token so progress events from the
+	// orchestrator can be relayed while the call runs.
+	requestID := t.toolCallRequestID(req.ID)
+	if params.Meta != nil && params.Meta.ProgressToken != nil {
+		t.trackProgress(requestID, params.Meta.ProgressToken)
+		defer t.untrackProgress(requestID)
+	}
+
+	resp, err := t.send(ctx, &pluginv1.PluginRequest{
+		RequestId: requestID,
 		Request: &pluginv1.PluginRequest_ToolCall{
 			ToolCall: &pluginv1.ToolRequest{
 				ToolName:     params.Name,
@@ -191,7 +351,7 @@ func (t *StdioTransport) handleToolsCall(ctx context.Context, req *protocol.JSON
 		}
 	}
 
-	mcpResult := ToolResponseToMCP(tc)
+	mcpResult := t.gateToolResult(t.checkOutput(params.Name, ToolResponseToMCP(tc)))
 
 	return &protocol.JSONRPCResponse{
 		JSONRPC: "2.0",
@@ -200,20 +360,91 @@ func (t *StdioTransport) handleToolsCall(ctx context.Context, req *protocol.JSON
 	}
 }
 
+// requestID returns the orchestrator request ID for a request of the given
+// kind, such as "tc" for tools/call, made for the JSON-RPC ID id. The sessions
+// of an HTTP endpoint share one Sender and reuse the same JSON-RPC IDs, so the
+// session ID keeps their request IDs, and the progress events and stream
+// cancels that name them, apart. Before the handshake there is no session ID.
+func (t *StdioTransport) requestID(kind string, id any) string {
+	if t.sessionID == "" {
+		return fmt.Sprintf("stdio-%s-%v", kind, id)
+	}
+	return fmt.Sprintf("stdio-%s-%s-%v", kind, t.sessionID, id)
+}
+
+// toolCallRequestID returns the orchestrator request ID used for the tools/call
+// with the given JSON-RPC ID.
+func (t *StdioTransport) toolCallRequestID(id any) string {
+	return t.requestID("tc", id)
+}
+
+// cancelledParams is the expected shape of params for notifications/cancelled.
+type cancelledParams struct {
+	RequestID any    `json:"requestId"`
+	Reason    string `json:"reason,omitempty"`
+}
+
+// cancelPropagationTimeout bounds how long the transport waits while telling
+// the orchestrator that a tool call was cancelled.
+const cancelPropagationTimeout = 5 * time.Second
+
+// handleCancelled aborts the in-flight request named by a notifications/cancelled
+// message. Unknown or already completed request IDs are ignored, as the MCP
+// spec allows.
+func (t *StdioTransport) handleCancelled(req *protocol.JSONRPCRequest) {
+	var params cancelledParams
+	if req.Params != nil {
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			slog.Debug("invalid notifications/cancelled params", "error", err)
+			return
+		}
+	}
+	if params.RequestID == nil {
+		return
+	}
+
+	if !t.cancelRequest(params.RequestID) {
+		slog.Debug("cancel for unknown request", "id", params.RequestID)
+		return
+	}
+	slog.Debug("request cancelled", "id", params.RequestID, "reason", params.Reason)
+}
+
+// propagateCancel tells the orchestrator that the client cancelled the tool
+// call with the given JSON-RPC ID so it can abort the plugin call. Failures are
+// logged and otherwise ignored; the client has already given up on the call.
+func (t *StdioTransport) propagateCancel(ctx context.Context, id any) {
+	cctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), cancelPropagationTimeout)
+	defer cancel()
+
+	_, err := t.sender.Send(cctx, &pluginv1.PluginRequest{
+		RequestId: t.requestID("cancel", id),
+		Request: &pluginv1.PluginRequest_StreamCancel{
+			StreamCancel: &pluginv1.StreamCancel{
+				StreamId: t.toolCallRequestID(id),
+			},
+		},
+	})
+	if err != nil {
+		slog.Debug("failed propagating cancellation", "id", id, "error", err)
+	}
+}
+
 // promptsListResult is the JSON shape for a prompts/list response.
 type promptsListResult struct {
-	Prompts []protocol.MCPPromptDefinition `json:"prompts"`
+	Prompts    []protocol.MCPPromptDefinition `json:"prompts"`
+	NextCursor string                         `json:"nextCursor,omitempty"`
 }
 
-// handlePromptsList queries the orchestrator for all registered prompts and
-// converts them to MCP format.
+// handlePromptsList queries the orchestrator for all registered prompts, or
+// uses the cached list, and converts them to MCP format, one page at a time.
 func (t *StdioTransport) handlePromptsList(ctx context.Context, req *protocol.JSONRPCRequest) *protocol.JSONRPCResponse {
-	resp, err := t.sender.Send(ctx, &pluginv1.PluginRequest{
-		RequestId: fmt.Sprintf("stdio-lp-%v", req.ID),
-		Request: &pluginv1.PluginRequest_ListPrompts{
-			ListPrompts: &pluginv1.ListPromptsRequest{},
-		},
-	})
+	cursor, errResp := t.listCursor(req)
+	if errResp != nil {
+		return errResp
+	}
+
+	lp, err := t.listPrompts(ctx, req.ID)
 	if err != nil {
 		return &protocol.JSONRPCResponse{
 			JSONRPC: "2.0",
@@ -225,7 +456,6 @@ func (t *StdioTransport) handlePromptsList(ctx context.Context, req *protocol.JS
 		}
 	}
 
-	lp := resp.GetListPrompts()
 	if lp == nil {
 		return &protocol.JSONRPCResponse{
 			JSONRPC: "2.0",
@@ -237,15 +467,31 @@ func (t *StdioTransport) handlePromptsList(ctx context.Context, req *protocol.JS
 		}
 	}
 
-	mcpPrompts := make([]protocol.MCPPromptDefinition, 0, len(lp.Prompts))
-	for _, pd := range lp.Prompts {
+	keys := make([]string, len(lp.Prompts))
+	for i, pd := range lp.Prompts {
+		keys[i] = pd.GetName()
+	}
+	start, end, next, err := t.page(cursor, keys)
+	if err != nil {
+		return &protocol.JSONRPCResponse{
+			JSONRPC: "2.0",
+			ID:      req.ID,
+			Error: &protocol.JSONRPCError{
+				Code:    protocol.InvalidParams,
+				Message: err.Error(),
+			},
+		}
+	}
+
+	mcpPrompts := make([]protocol.MCPPromptDefinition, 0, end-start)
+	for _, pd := range lp.Prompts[start:end] {
 		mcpPrompts = append(mcpPrompts, PromptDefinitionToMCP(pd))
 	}
 
 	return &protocol.JSONRPCResponse{
 		JSONRPC: "2.0",
 		ID:      req.ID,
-		Result:  promptsListResult{Prompts: mcpPrompts},
+		Result:  promptsListResult{Prompts: mcpPrompts, NextCursor: next},
 	}
 }
 
@@ -284,8 +530,8 @@ func (t *StdioTransport) handlePromptsGet(ctx context.Context, req *protocol.JSO
 		}
 	}
 
-	resp, err := t.sender.Send(ctx, &pluginv1.PluginRequest{
-		RequestId: fmt.Sprintf("stdio-pg-%v", req.ID),
+	resp, err := t.send(ctx, &pluginv1.PluginRequest{
+		RequestId: t.requestID("pg", req.ID),
 		Request: &pluginv1.PluginRequest_PromptGet{
 			PromptGet: &pluginv1.PromptGetRequest{
 				PromptName: params.Name,
@@ -327,64 +573,67 @@ func (t *StdioTransport) handlePromptsGet(ctx context.Context, req *protocol.JSO
 
 // --- Resources handlers ---
 
-// resourcePrefixes are the storage prefixes exposed as MCP resources.
-var resourcePrefixes = []struct {
-	prefix   string // storage prefix (e.g. "features/")
-	scheme   string // URI scheme segment (e.g. "features")
-	name     string // human-readable name for templates
-	mimeType string
-}{
-	{"features/", "features", "Project Features", "text/markdown"},
-	{"notes/", "notes", "Project Notes", "text/markdown"},
-	{"docs/", "docs", "Project Documentation", "text/markdown"},
-}
-
 // resourcesListResult is the JSON shape for a resources/list response.
 type resourcesListResult struct {
-	Resources []protocol.MCPResource `json:"resources"`
+	Resources  []Resource         `json:"resources"`
+	NextCursor string             `json:"nextCursor,omitempty"`
+	Meta       *resourcesListMeta `json:"_meta,omitempty"`
 }
 
 // handleResourcesList lists all available resources by querying storage for
-// each known prefix.
+// each resource namespace, one page at a time. The resources of the page are
+// read for their descriptions. Namespaces that cannot be listed are reported
+// to the client and in the response's _meta.
 func (t *StdioTransport) handleResourcesList(ctx context.Context, req *protocol.JSONRPCRequest) *protocol.JSONRPCResponse {
-	var resources []protocol.MCPResource
-
-	for _, rp := range resourcePrefixes {
-		resp, err := t.sender.Send(ctx, &pluginv1.PluginRequest{
-			RequestId: fmt.Sprintf("stdio-rl-%v-%s", req.ID, rp.scheme),
-			Request: &pluginv1.PluginRequest_StorageList{
-				StorageList: &pluginv1.StorageListRequest{
-					Prefix: rp.prefix,
-				},
-			},
-		})
-		if err != nil {
-			continue
-		}
-		sl := resp.GetStorageList()
-		if sl == nil {
-			continue
-		}
-		for _, entry := range sl.GetEntries() {
+	cursor, errResp := t.listCursor(req)
+	if errResp != nil {
+		return errResp
+	}
+
+	listings := t.listNamespaces(ctx, req.ID)
+	meta := t.reportListErrors(listings)
+
+	var resources []Resource
+	var storagePaths []string
+	for i, l := range listings {
+		ns := t.namespaces[i]
+		for _, entry := range l.entries {
+			// Entries of a nested namespace are listed under that namespace.
+			if owner, ok := t.namespaceForPath(entry.GetPath()); !ok || owner.Scheme != ns.Scheme {
+				continue
+			}
 			// Extract the ID from the path (e.g. "features/FEAT-ABC.md" -> "FEAT-ABC")
-			name := entry.GetPath()
-			// Remove prefix
-			name = strings.TrimPrefix(name, rp.prefix)
-			// Remove .md extension if present
-			name = strings.TrimSuffix(name, ".md")
+			name, ok := ns.resourceID(entry.GetPath())
+			if !ok {
+				continue
+			}
+			resources = append(resources, t.listedResource(ns, ns.uri(name), name, entry))
+			storagePaths = append(storagePaths, entry.GetPath())
+		}
+	}
 
-			resources = append(resources, protocol.MCPResource{
-				URI:      fmt.Sprintf("orchestra://%s/%s", rp.scheme, name),
-				Name:     name,
-				MimeType: rp.mimeType,
-			})
+	keys := make([]string, len(resources))
+	for i, r := range resources {
+		keys[i] = r.URI
+	}
+	start, end, next, err := t.page(cursor, keys)
+	if err != nil {
+		return &protocol.JSONRPCResponse{
+			JSONRPC: "2.0",
+			ID:      req.ID,
+			Error: &protocol.JSONRPCError{
+				Code:    protocol.InvalidParams,
+				Message: err.Error(),
+			},
 		}
 	}
+	resources = resources[start:end]
+	t.describeResources(ctx, req.ID, resources, storagePaths[start:end])
 
 	return &protocol.JSONRPCResponse{
 		JSONRPC: "2.0",
 		ID:      req.ID,
-		Result:  resourcesListResult{Resources: resources},
+		Result:  resourcesListResult{Resources: resources, NextCursor: next, Meta: meta},
 	}
 }
 
@@ -395,7 +644,7 @@ type resourcesReadParams struct {
 
 // resourcesReadResult is the JSON shape for a resources/read response.
 type resourcesReadResult struct {
-	Contents []protocol.MCPResourceContent `json:"contents"`
+	Contents []ResourceContents `json:"contents"`
 }
 
 // handleResourcesRead reads a single resource by URI.
@@ -425,99 +674,141 @@ func (t *StdioTransport) handleResourcesRead(ctx context.Context, req *protocol.
 		}
 	}
 
-	// Parse URI: "orchestra://features/FEAT-ABC" -> prefix "features/", id "FEAT-ABC"
-	const uriPrefix = "orchestra://"
-	if !strings.HasPrefix(params.URI, uriPrefix) {
+	res, err := t.resolveResourceURI(params.URI)
+	if err != nil {
 		return &protocol.JSONRPCResponse{
 			JSONRPC: "2.0",
 			ID:      req.ID,
 			Error: &protocol.JSONRPCError{
 				Code:    protocol.InvalidParams,
-				Message: fmt.Sprintf("unsupported URI scheme: %q (expected orchestra://)", params.URI),
+				Message: err.Error(),
 			},
 		}
 	}
 
-	rest := strings.TrimPrefix(params.URI, uriPrefix)
-	parts := strings.SplitN(rest, "/", 2)
-	if len(parts) != 2 || parts[1] == "" {
+	sr, err := t.readStorage(ctx, req.ID, res.storagePath)
+	if err != nil {
 		return &protocol.JSONRPCResponse{
 			JSONRPC: "2.0",
 			ID:      req.ID,
 			Error: &protocol.JSONRPCError{
-				Code:    protocol.InvalidParams,
-				Message: fmt.Sprintf("invalid resource URI: %q", params.URI),
+				Code:    protocol.InternalError,
+				Message: fmt.Sprintf("storage read failed: %v", err),
 			},
 		}
 	}
 
-	scheme := parts[0]
-	id := parts[1]
-
-	// Find the matching prefix.
-	var storagePath string
-	var mimeType string
-	for _, rp := range resourcePrefixes {
-		if rp.scheme == scheme {
-			storagePath = rp.prefix + id + ".md"
-			mimeType = rp.mimeType
-			break
-		}
-	}
-	if storagePath == "" {
+	if sr == nil {
 		return &protocol.JSONRPCResponse{
 			JSONRPC: "2.0",
 			ID:      req.ID,
 			Error: &protocol.JSONRPCError{
-				Code:    protocol.InvalidParams,
-				Message: fmt.Sprintf("unknown resource type: %q", scheme),
+				Code:    protocol.InternalError,
+				Message: "unexpected response type from storage",
 			},
 		}
 	}
 
-	resp, err := t.sender.Send(ctx, &pluginv1.PluginRequest{
-		RequestId: fmt.Sprintf("stdio-rr-%v", req.ID),
-		Request: &pluginv1.PluginRequest_StorageRead{
-			StorageRead: &pluginv1.StorageReadRequest{
-				Path: storagePath,
-			},
-		},
-	})
-	if err != nil {
-		return &protocol.JSONRPCResponse{
+	// Binary content, such as images and PDFs, is returned as a base64 blob.
+	content := sr.GetContent()
+	contents := ResourceContents{
+		URI:      params.URI,
+		MimeType: resourceMimeType(res.ns, res.storagePath, sr.GetMetadata(), content),
+	}
+	if isTextContent(contents.MimeType, content) {
+		contents.Text = string(content)
+	} else {
+		contents.Blob = base64.StdEncoding.EncodeToString(content)
+	}
+
+	return &protocol.JSONRPCResponse{
+		JSONRPC: "2.0",
+		ID:      req.ID,
+		Result:  resourcesReadResult{Contents: []ResourceContents{contents}},
+	}
+}
+
+// resourceSubscribeParams is the expected shape of params for
+// resources/subscribe and resources/unsubscribe.
+type resourceSubscribeParams struct {
+	URI string `json:"uri"`
+}
+
+// parseResourceSubscribeParams validates the params of a subscribe or
+// unsubscribe request. On failure it returns the error response to send.
+func (t *StdioTransport) parseResourceSubscribeParams(req *protocol.JSONRPCRequest) (resourceSubscribeParams, *protocol.JSONRPCResponse) {
+	var params resourceSubscribeParams
+	if req.Params != nil {
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return params, &protocol.JSONRPCResponse{
+				JSONRPC: "2.0",
+				ID:      req.ID,
+				Error: &protocol.JSONRPCError{
+					Code:    protocol.InvalidParams,
+					Message: fmt.Sprintf("invalid params: %v", err),
+				},
+			}
+		}
+	}
+
+	if params.URI == "" {
+		return params, &protocol.JSONRPCResponse{
 			JSONRPC: "2.0",
 			ID:      req.ID,
 			Error: &protocol.JSONRPCError{
-				Code:    protocol.InternalError,
-				Message: fmt.Sprintf("storage read failed: %v", err),
+				Code:    protocol.InvalidParams,
+				Message: "missing required parameter: uri",
 			},
 		}
 	}
 
-	sr := resp.GetStorageRead()
-	if sr == nil {
-		return &protocol.JSONRPCResponse{
+	res, err := t.resolveResourceURI(params.URI)
+	if err != nil {
+		return params, &protocol.JSONRPCResponse{
 			JSONRPC: "2.0",
 			ID:      req.ID,
 			Error: &protocol.JSONRPCError{
-				Code:    protocol.InternalError,
-				Message: "unexpected response type from storage",
+				Code:    protocol.InvalidParams,
+				Message: err.Error(),
 			},
 		}
 	}
+	// Subscriptions are kept by canonical URI, the form storage events map to.
+	params.URI = res.uri
+	return params, nil
+}
+
+// handleResourcesSubscribe subscribes the client to change notifications for
+// a single resource. Updates are driven by storage events from the
+// orchestrator's event stream.
+func (t *StdioTransport) handleResourcesSubscribe(req *protocol.JSONRPCRequest) *protocol.JSONRPCResponse {
+	params, errResp := t.parseResourceSubscribeParams(req)
+	if errResp != nil {
+		return errResp
+	}
+
+	t.subscribe(params.URI)
 
 	return &protocol.JSONRPCResponse{
 		JSONRPC: "2.0",
 		ID:      req.ID,
-		Result: resourcesReadResult{
-			Contents: []protocol.MCPResourceContent{
-				{
-					URI:      params.URI,
-					MimeType: mimeType,
-					Text:     string(sr.GetContent()),
-				},
-			},
-		},
+		Result:  map[string]any{},
+	}
+}
+
+// handleResourcesUnsubscribe cancels a previous resources/subscribe.
+func (t *StdioTransport) handleResourcesUnsubscribe(req *protocol.JSONRPCRequest) *protocol.JSONRPCResponse {
+	params, errResp := t.parseResourceSubscribeParams(req)
+	if errResp != nil {
+		return errResp
+	}
+
+	t.unsubscribe(params.URI)
+
+	return &protocol.JSONRPCResponse{
+		JSONRPC: "2.0",
+		ID:      req.ID,
+		Result:  map[string]any{},
 	}
 }
 
@@ -526,15 +817,16 @@ type resourceTemplatesListResult struct {
 	ResourceTemplates []protocol.MCPResourceTemplate `json:"resourceTemplates"`
 }
 
-// handleResourceTemplatesList returns the static resource URI templates.
+// handleResourceTemplatesList returns a URI template for each resource
+// namespace.
 func (t *StdioTransport) handleResourceTemplatesList(req *protocol.JSONRPCRequest) *protocol.JSONRPCResponse {
-	templates := make([]protocol.MCPResourceTemplate, 0, len(resourcePrefixes))
-	for _, rp := range resourcePrefixes {
+	templates := make([]protocol.MCPResourceTemplate, 0, len(t.namespaces))
+	for _, ns := range t.namespaces {
 		templates = append(templates, protocol.MCPResourceTemplate{
-			URITemplate: fmt.Sprintf("orchestra://%s/{id}", rp.scheme),
-			Name:        rp.name,
-			Description: fmt.Sprintf("Access %s by ID", rp.name),
-			MimeType:    rp.mimeType,
+			URITemplate: ns.uriTemplate(),
+			Name:        ns.Name,
+			Description: fmt.Sprintf("Access %s by ID", ns.Name),
+			MimeType:    ns.mimeType(),
 		})
 	}
 
@@ -578,7 +870,9 @@ func (t *StdioTransport) handleLoggingSetLevel(req *protocol.JSONRPCRequest) *pr
 		}
 	}
 
+	t.mu.Lock()
 	t.logLevel = level
+	t.mu.Unlock()
 
 	return &protocol.JSONRPCResponse{
 		JSONRPC: "2.0",
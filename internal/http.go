@@ -0,0 +1,583 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	pluginv1 "github.com/orchestra-mcp/gen-go/orchestra/plugin/v1"
+	"github.com/orchestra-mcp/sdk-go/protocol"
+)
+
+// sessionHeader carries the MCP session ID on Streamable HTTP requests. Its
+// value is the session ID assigned by handleInitialize.
+const sessionHeader = "Mcp-Session-Id"
+
+// Default session limits of an HTTPTransport. The endpoint is
+// unauthenticated, so by default neither the number of sessions nor their
+// lifetime is unbounded.
+const (
+	defaultMaxSessions        = 100
+	defaultSessionIdleTimeout = 30 * time.Minute
+)
+
+var (
+	errMissingSession  = errors.New("missing " + sessionHeader + " header")
+	errUnknownSession  = errors.New("unknown or terminated session")
+	errTooManySessions = errors.New("server busy: too many sessions")
+)
+
+// WithAllowedOrigins lets browser pages from the given origins, such as
+// "https://app.example.com", call the Streamable HTTP endpoint. Pages served
+// from localhost are always allowed. It has no effect on the stdio loop.
+func WithAllowedOrigins(origins ...string) func(*StdioTransport) {
+	return func(t *StdioTransport) {
+		t.allowedOrigins = append(t.allowedOrigins, origins...)
+	}
+}
+
+// WithMaxSessions limits how many sessions the Streamable HTTP endpoint keeps
+// open. Further initialize requests are rejected with 503 Service Unavailable
+// until a session ends. A limit of 0 means unlimited. It has no effect on the
+// stdio loop.
+func WithMaxSessions(n int) func(*StdioTransport) {
+	return func(t *StdioTransport) {
+		t.maxSessions = n
+	}
+}
+
+// WithSessionIdleTimeout closes Streamable HTTP sessions that have had no
+// request for the given duration. A session with a request in progress or an
+// open event stream is not idle. A timeout of 0 keeps sessions until the
+// client deletes them. It has no effect on the stdio loop.
+func WithSessionIdleTimeout(d time.Duration) func(*StdioTransport) {
+	return func(t *StdioTransport) {
+		t.sessionIdleTimeout = d
+	}
+}
+
+// HTTPTransport serves MCP over the Streamable HTTP transport. Clients POST
+// JSON-RPC messages to a single endpoint and may open a GET Server-Sent Events
+// stream to receive server-initiated messages (notifications). Each MCP session
+// is backed by its own StdioTransport so requests go through the same dispatch
+// handlers as the stdio loop.
+type HTTPTransport struct {
+	sender         Sender
+	opts           []func(*StdioTransport)
+	eventCh        <-chan *pluginv1.EventDelivery
+	maxMessageSize int
+	allowedOrigins []string       // see WithAllowedOrigins
+	maxSessions    int            // see WithMaxSessions
+	idleTimeout    time.Duration  // see WithSessionIdleTimeout
+	resourceCache  *resourceCache // shared by the sessions; see WithResourceCache
+
+	mu       sync.Mutex
+	sessions map[string]*httpSession
+}
+
+// httpSession is a single MCP session served over HTTP.
+type httpSession struct {
+	t      *StdioTransport
+	stream *sseStream
+
+	active   atomic.Int32 // requests being served, including an open GET stream
+	lastUsed atomic.Int64 // when the last request ended, in Unix nanoseconds
+}
+
+// NewHTTPTransport creates a Streamable HTTP transport. The options are the
+// same as for NewStdioTransport and are applied to every session. If an event
+// channel is configured, Run fans its events out to all sessions.
+func NewHTTPTransport(sender Sender, opts ...func(*StdioTransport)) *HTTPTransport {
+	// Apply the options to a template transport to pick up the shared event
+	// channel, message size limit, allowed origins and session limits;
+	// sessions never run the stdio loop that would consume the channel.
+	tmpl := &StdioTransport{
+		maxMessageSize:     defaultMaxMessageSize,
+		maxSessions:        defaultMaxSessions,
+		sessionIdleTimeout: defaultSessionIdleTimeout,
+	}
+	for _, opt := range opts {
+		opt(tmpl)
+	}
+	return &HTTPTransport{
+		sender:         sender,
+		opts:           opts,
+		eventCh:        tmpl.eventCh,
+		maxMessageSize: tmpl.maxMessageSize,
+		allowedOrigins: tmpl.allowedOrigins,
+		maxSessions:    tmpl.maxSessions,
+		idleTimeout:    tmpl.sessionIdleTimeout,
+		resourceCache:  tmpl.resourceCache,
+		sessions:       make(map[string]*httpSession),
+	}
+}
+
+// Run delivers events from the configured event channel to every open session
+// and closes idle sessions, until the channel is closed or ctx is cancelled.
+// It then closes all sessions.
+func (h *HTTPTransport) Run(ctx context.Context) error {
+	defer h.closeAll()
+	var expire <-chan time.Time
+	if h.idleTimeout > 0 {
+		ticker := time.NewTicker(h.idleTimeout / 2)
+		defer ticker.Stop()
+		expire = ticker.C
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case now := <-expire:
+			h.closeIdle(now)
+		case ev, ok := <-h.eventCh:
+			if !ok {
+				return nil
+			}
+			h.deliver(ev)
+		}
+	}
+}
+
+// deliver passes an event to the sessions it concerns: a client request to
+// the session chosen by sessionFor, any other event to every open session.
+// A storage event first invalidates the shared resource cache, which must
+// happen even if no session is open.
+func (h *HTTPTransport) deliver(ev *pluginv1.EventDelivery) {
+	switch ev.GetTopic() {
+	case samplingRequestTopic, elicitationRequestTopic:
+		h.sessionFor(ev).forwardClientRequest(ev)
+		return
+	case storageWriteTopic, storageDeleteTopic:
+		h.resourceCache.invalidateStorageEvent(ev.GetTopic(), ev.GetPayload())
+	}
+	for _, s := range h.snapshot() {
+		if err := s.t.handleEvent(ev); err != nil {
+			slog.Debug("failed delivering event to session", "session", s.t.sessionID, "error", err)
+		}
+	}
+}
+
+// sessionFor picks the session that handles a request for a client, such as a
+// sampling or elicitation request: the session named by the event's
+// "session_id" field, or else any ready session. If there is none, the
+// request goes to a detached transport that answers it with an error.
+func (h *HTTPTransport) sessionFor(ev *pluginv1.EventDelivery) *StdioTransport {
+	if id := ev.GetPayload().GetFields()["session_id"].GetStringValue(); id != "" {
+		if s, err := h.lookup(id); err == nil {
+			return s.t
+		}
+	} else {
+		for _, s := range h.snapshot() {
+			if s.t.State() == StateReady {
+				return s.t
+			}
+		}
+	}
+	return NewStdioTransport(h.sender, nil, io.Discard, h.opts...)
+}
+
+// ServeHTTP implements http.Handler for the single MCP endpoint. Requests from
+// browser pages of other origins are rejected, so a page cannot reach the
+// endpoint through DNS rebinding.
+func (h *HTTPTransport) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if origin := r.Header.Get("Origin"); !h.originAllowed(origin) {
+		slog.Debug("rejected request from foreign origin", "origin", origin)
+		http.Error(w, "forbidden origin", http.StatusForbidden)
+		return
+	}
+	switch r.Method {
+	case http.MethodPost:
+		h.handlePost(w, r)
+	case http.MethodGet:
+		h.handleGet(w, r)
+	case http.MethodDelete:
+		h.handleDelete(w, r)
+	default:
+		w.Header().Set("Allow", "GET, POST, DELETE")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// originAllowed reports whether a request's Origin header is acceptable: absent,
+// as from clients that are not browsers, a localhost origin, or one of the
+// allowed origins. The request's Host is not trusted, since DNS rebinding
+// makes a foreign page same-origin with the endpoint.
+func (h *HTTPTransport) originAllowed(origin string) bool {
+	if origin == "" {
+		return true
+	}
+	for _, o := range h.allowedOrigins {
+		if strings.EqualFold(strings.TrimSuffix(o, "/"), origin) {
+			return true
+		}
+	}
+	u, err := url.Parse(origin)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return false
+	}
+	host := u.Hostname()
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// SendToolsListChanged sends a notifications/tools/list_changed notification
+// to every open session.
+func (h *HTTPTransport) SendToolsListChanged() {
+	for _, s := range h.snapshot() {
+		s.t.SendToolsListChanged()
+	}
+}
+
+// SendPromptsListChanged sends a notifications/prompts/list_changed
+// notification to every open session.
+func (h *HTTPTransport) SendPromptsListChanged() {
+	for _, s := range h.snapshot() {
+		s.t.SendPromptsListChanged()
+	}
+}
+
+// SendLogNotification sends a notifications/message notification to every
+// open session whose log level admits it.
+func (h *HTTPTransport) SendLogNotification(level protocol.MCPLogLevel, logger, data string) {
+	for _, s := range h.snapshot() {
+		s.t.SendLogNotification(level, logger, data)
+	}
+}
+
+// handlePost dispatches one JSON-RPC message or batch. An initialize request
+// creates a new session and returns its ID in the Mcp-Session-Id header, or
+// is answered with 503 if the session limit is reached; every other message
+// must carry that header. Requests are answered with a JSON body;
+// notifications are acknowledged with 202 Accepted.
+func (h *HTTPTransport) handlePost(w http.ResponseWriter, r *http.Request) {
+	var body io.Reader = r.Body
+	if h.maxMessageSize > 0 {
+		body = http.MaxBytesReader(w, r.Body, int64(h.maxMessageSize))
+	}
+	raw, err := io.ReadAll(body)
+	if err != nil {
+		writeHTTPError(w, http.StatusRequestEntityTooLarge, nil, protocol.InvalidRequest, fmt.Sprintf("read body: %v", err))
+		return
+	}
+
+	msg := bytes.TrimSpace(raw)
+	if isBatch(msg) {
+		h.handleBatch(w, r, msg)
+		return
+	}
+
+	var req protocol.JSONRPCRequest
+	if err := json.Unmarshal(msg, &req); err != nil {
+		writeHTTPError(w, http.StatusBadRequest, nil, protocol.ParseError, fmt.Sprintf("parse error: %v", err))
+		return
+	}
+
+	var s *httpSession
+	if req.Method == "initialize" {
+		s = h.newSession()
+	} else {
+		s, err = h.lookup(r.Header.Get(sessionHeader))
+		if err != nil {
+			writeHTTPError(w, sessionErrorStatus(err), req.ID, protocol.InvalidRequest, err.Error())
+			return
+		}
+	}
+	defer s.begin()()
+
+	if req.Method == "" && s.t.deliverClientResponse(msg) {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	if errResp, ok := s.t.admit(&req); !ok {
+		if errResp == nil {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		writeHTTPJSON(w, http.StatusOK, errResp)
+		return
+	}
+
+	ctx, done := s.t.trackRequest(r.Context(), req.ID)
+	resp := s.t.dispatch(ctx, &req)
+	done()
+
+	if req.Method == "initialize" {
+		if resp.Error != nil {
+			writeHTTPJSON(w, http.StatusOK, resp)
+			return
+		}
+		if !h.register(s) {
+			s.t.closeSession()
+			writeHTTPError(w, http.StatusServiceUnavailable, req.ID, codeServerBusy, errTooManySessions.Error())
+			return
+		}
+		w.Header().Set(sessionHeader, s.t.sessionID)
+	}
+
+	if errors.Is(context.Cause(ctx), errRequestCancelled) {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if resp == nil {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+	writeHTTPJSON(w, http.StatusOK, resp)
+}
+
+// handleBatch dispatches a JSON-RPC batch within an existing session and
+// answers with a JSON array, or 202 Accepted if the batch held only
+// notifications. A malformed or empty batch is answered with 400.
+func (h *HTTPTransport) handleBatch(w http.ResponseWriter, r *http.Request, msg []byte) {
+	s, err := h.lookup(r.Header.Get(sessionHeader))
+	if err != nil {
+		writeHTTPError(w, sessionErrorStatus(err), nil, protocol.InvalidRequest, err.Error())
+		return
+	}
+	defer s.begin()()
+
+	collect, errResp := s.t.startBatch(r.Context(), msg)
+	if errResp != nil {
+		writeHTTPJSON(w, http.StatusBadRequest, errResp)
+		return
+	}
+	resps := collect()
+	if len(resps) == 0 {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+	writeHTTPJSON(w, http.StatusOK, resps)
+}
+
+// handleGet opens the Server-Sent Events stream on which the session's
+// server-initiated messages are delivered. Only one stream per session may be
+// open at a time.
+func (h *HTTPTransport) handleGet(w http.ResponseWriter, r *http.Request) {
+	if !strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+		http.Error(w, "GET requires Accept: text/event-stream", http.StatusNotAcceptable)
+		return
+	}
+	s, err := h.lookup(r.Header.Get(sessionHeader))
+	if err != nil {
+		http.Error(w, err.Error(), sessionErrorStatus(err))
+		return
+	}
+	defer s.begin()()
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	if !s.stream.attach(w, flusher) {
+		http.Error(w, "stream already open for session", http.StatusConflict)
+		return
+	}
+	defer s.stream.detach()
+
+	<-r.Context().Done()
+}
+
+// handleDelete terminates a session at the client's request.
+func (h *HTTPTransport) handleDelete(w http.ResponseWriter, r *http.Request) {
+	id := r.Header.Get(sessionHeader)
+	if _, err := h.lookup(id); err != nil {
+		http.Error(w, err.Error(), sessionErrorStatus(err))
+		return
+	}
+	h.close(id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// newSession creates an unregistered session. It is registered once the
+// initialize request has assigned its session ID.
+func (h *HTTPTransport) newSession() *httpSession {
+	stream := &sseStream{}
+	t := NewStdioTransport(h.sender, nil, stream, h.opts...)
+	t.sharedCache = true
+	return &httpSession{t: t, stream: stream}
+}
+
+// register makes a session reachable by its session ID. It reports false if
+// the session limit is reached.
+func (h *HTTPTransport) register(s *httpSession) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.maxSessions > 0 && len(h.sessions) >= h.maxSessions {
+		return false
+	}
+	s.lastUsed.Store(time.Now().UnixNano())
+	h.sessions[s.t.sessionID] = s
+	return true
+}
+
+// begin marks a request to the session as in progress until the returned
+// func is called, so that the session is not closed as idle meanwhile.
+func (s *httpSession) begin() func() {
+	s.active.Add(1)
+	return func() {
+		s.lastUsed.Store(time.Now().UnixNano())
+		s.active.Add(-1)
+	}
+}
+
+// idleSince reports whether the session has had no request in progress since
+// before t.
+func (s *httpSession) idleSince(t time.Time) bool {
+	return s.active.Load() == 0 && s.lastUsed.Load() < t.UnixNano()
+}
+
+// lookup finds a session by the ID from the Mcp-Session-Id header.
+func (h *HTTPTransport) lookup(id string) (*httpSession, error) {
+	if id == "" {
+		return nil, errMissingSession
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s, ok := h.sessions[id]
+	if !ok {
+		return nil, errUnknownSession
+	}
+	return s, nil
+}
+
+// sessionErrorStatus maps a lookup error to its HTTP status: 400 when the
+// session header is missing and 404 for unknown sessions, which tells the
+// client to start a new session.
+func sessionErrorStatus(err error) int {
+	if errors.Is(err, errMissingSession) {
+		return http.StatusBadRequest
+	}
+	return http.StatusNotFound
+}
+
+// snapshot returns the currently open sessions.
+func (h *HTTPTransport) snapshot() []*httpSession {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	out := make([]*httpSession, 0, len(h.sessions))
+	for _, s := range h.sessions {
+		out = append(out, s)
+	}
+	return out
+}
+
+// close removes a session, ends it so that its pending requests to the
+// client fail, and invokes the onDisconnect callback for it.
+func (h *HTTPTransport) close(id string) {
+	h.mu.Lock()
+	s, ok := h.sessions[id]
+	delete(h.sessions, id)
+	h.mu.Unlock()
+	if ok {
+		s.t.closeSession()
+	}
+	if ok && s.t.onDisconnect != nil {
+		s.t.onDisconnect(id)
+	}
+}
+
+// closeIdle closes the sessions that have been idle for the idle timeout.
+func (h *HTTPTransport) closeIdle(now time.Time) {
+	cutoff := now.Add(-h.idleTimeout)
+	for _, s := range h.snapshot() {
+		if s.idleSince(cutoff) {
+			slog.Debug("closing idle session", "session", s.t.sessionID)
+			h.close(s.t.sessionID)
+		}
+	}
+}
+
+// closeAll closes every open session.
+func (h *HTTPTransport) closeAll() {
+	for _, s := range h.snapshot() {
+		h.close(s.t.sessionID)
+	}
+}
+
+// writeHTTPJSON writes a JSON-RPC response, or an array of them, as the HTTP
+// response body.
+func writeHTTPJSON(w http.ResponseWriter, status int, v any) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("marshal response: %v", err), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(data)
+}
+
+// writeHTTPError writes a JSON-RPC error response with the given HTTP status.
+func writeHTTPError(w http.ResponseWriter, status int, id any, code int, msg string) {
+	writeHTTPJSON(w, status, &protocol.JSONRPCResponse{
+		JSONRPC: "2.0",
+		ID:      id,
+		Error: &protocol.JSONRPCError{
+			Code:    code,
+			Message: msg,
+		},
+	})
+}
+
+// sseStream is the output writer of an HTTP session. It relays each JSON line
+// written by the session's StdioTransport to the client's open GET stream as a
+// Server-Sent Event. Messages written while no stream is open are dropped.
+type sseStream struct {
+	mu      sync.Mutex
+	w       http.ResponseWriter
+	flusher http.Flusher
+}
+
+// attach connects an open GET stream and sends its response headers. It
+// reports false if a stream is already attached.
+func (s *sseStream) attach(w http.ResponseWriter, f http.Flusher) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.w != nil {
+		return false
+	}
+	w.WriteHeader(http.StatusOK)
+	f.Flush()
+	s.w, s.flusher = w, f
+	return true
+}
+
+// detach disconnects the current GET stream.
+func (s *sseStream) detach() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.w, s.flusher = nil, nil
+}
+
+// Write implements io.Writer.
+func (s *sseStream) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.w == nil {
+		slog.Debug("dropping server message: no stream open")
+		return len(p), nil
+	}
+	if _, err := fmt.Fprintf(s.w, "event: message\ndata: %s\n\n", bytes.TrimSpace(p)); err != nil {
+		return 0, err
+	}
+	s.flusher.Flush()
+	return len(p), nil
+}
@@ -0,0 +1,407 @@
+package internal
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	pluginv1 "github.com/orchestra-mcp/gen-go/orchestra/plugin/v1"
+	"github.com/orchestra-mcp/sdk-go/protocol"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// postJSON posts a JSON-RPC message to the endpoint, optionally with a session
+// header, and returns the HTTP response.
+func postJSON(t *testing.T, url, sessionID, body string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("new request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json, text/event-stream")
+	if sessionID != "" {
+		req.Header.Set(sessionHeader, sessionID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("POST: %v", err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+// decodeHTTPResponse decodes a JSON-RPC response from an HTTP response body.
+func decodeHTTPResponse(t *testing.T, resp *http.Response) protocol.JSONRPCResponse {
+	t.Helper()
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("read body: %v", err)
+	}
+	return parseJSONRPCResponse(t, string(raw))
+}
+
+// initHTTPSession runs the initialize handshake against the server and returns
+// the session ID.
+func initHTTPSession(t *testing.T, url string) string {
+	t.Helper()
+	resp := postJSON(t, url, "", `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("initialize status: got %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	rpc := decodeHTTPResponse(t, resp)
+	if rpc.Error != nil {
+		t.Fatalf("initialize error: %+v", rpc.Error)
+	}
+	id := resp.Header.Get(sessionHeader)
+	if id == "" {
+		t.Fatal("expected Mcp-Session-Id header on initialize response")
+	}
+	if resp := postJSON(t, url, id, `{"jsonrpc":"2.0","method":"notifications/initialized"}`); resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("initialized status: got %d, want %d", resp.StatusCode, http.StatusAccepted)
+	}
+	return id
+}
+
+func TestHTTPInitializeAssignsSession(t *testing.T) {
+	srv := httptest.NewServer(NewHTTPTransport(&mockSender{}))
+	defer srv.Close()
+
+	resp := postJSON(t, srv.URL, "", `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`)
+	id := resp.Header.Get(sessionHeader)
+	rpc := decodeHTTPResponse(t, resp)
+
+	raw, _ := json.Marshal(rpc.Result)
+	var result protocol.MCPInitializeResult
+	if err := json.Unmarshal(raw, &result); err != nil {
+		t.Fatalf("unmarshal init result: %v", err)
+	}
+	if id == "" || result.SessionID != id {
+		t.Errorf("session header %q should match _sessionId %q", id, result.SessionID)
+	}
+}
+
+func TestHTTPToolsCall(t *testing.T) {
+	sender := &mockSender{
+		sendFunc: func(ctx context.Context, req *pluginv1.PluginRequest) (*pluginv1.PluginResponse, error) {
+			result, _ := structpb.NewStruct(map[string]any{"text": "session " + req.GetToolCall().SessionId})
+			return &pluginv1.PluginResponse{
+				Response: &pluginv1.PluginResponse_ToolCall{
+					ToolCall: &pluginv1.ToolResponse{Success: true, Result: result},
+				},
+			}, nil
+		},
+	}
+	srv := httptest.NewServer(NewHTTPTransport(sender))
+	defer srv.Close()
+
+	id := initHTTPSession(t, srv.URL)
+	resp := postJSON(t, srv.URL, id, `{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"whoami"}}`)
+	if ct := resp.Header.Get("Content-Type"); ct != "application/json" {
+		t.Errorf("content type: got %q", ct)
+	}
+	rpc := decodeHTTPResponse(t, resp)
+	if rpc.Error != nil {
+		t.Fatalf("unexpected error: %+v", rpc.Error)
+	}
+
+	raw, _ := json.Marshal(rpc.Result)
+	var result protocol.MCPToolResult
+	json.Unmarshal(raw, &result)
+	if len(result.Content) != 1 || result.Content[0].Text != "session "+id {
+		t.Errorf("expected tool call to carry the session ID, got %+v", result.Content)
+	}
+}
+
+func TestHTTPSessionErrors(t *testing.T) {
+	srv := httptest.NewServer(NewHTTPTransport(&mockSender{}))
+	defer srv.Close()
+
+	missing := postJSON(t, srv.URL, "", `{"jsonrpc":"2.0","id":1,"method":"ping"}`)
+	if missing.StatusCode != http.StatusBadRequest {
+		t.Errorf("missing session: got status %d, want %d", missing.StatusCode, http.StatusBadRequest)
+	}
+
+	unknown := postJSON(t, srv.URL, "nope", `{"jsonrpc":"2.0","id":1,"method":"ping"}`)
+	if unknown.StatusCode != http.StatusNotFound {
+		t.Errorf("unknown session: got status %d, want %d", unknown.StatusCode, http.StatusNotFound)
+	}
+
+	bad := postJSON(t, srv.URL, "", `{not json`)
+	if bad.StatusCode != http.StatusBadRequest {
+		t.Errorf("parse error: got status %d, want %d", bad.StatusCode, http.StatusBadRequest)
+	}
+	if rpc := decodeHTTPResponse(t, bad); rpc.Error == nil || rpc.Error.Code != protocol.ParseError {
+		t.Errorf("expected ParseError, got %+v", rpc.Error)
+	}
+}
+
+func TestHTTPNotificationAccepted(t *testing.T) {
+	srv := httptest.NewServer(NewHTTPTransport(&mockSender{}))
+	defer srv.Close()
+
+	id := initHTTPSession(t, srv.URL)
+	resp := postJSON(t, srv.URL, id, `{"jsonrpc":"2.0","method":"notifications/initialized"}`)
+	if resp.StatusCode != http.StatusAccepted {
+		t.Errorf("status: got %d, want %d", resp.StatusCode, http.StatusAccepted)
+	}
+}
+
+func TestHTTPServerSentEvents(t *testing.T) {
+	h := NewHTTPTransport(&mockSender{})
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	id := initHTTPSession(t, srv.URL)
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set(sessionHeader, id)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET status: got %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+
+	h.SendToolsListChanged()
+
+	data := make(chan string, 1)
+	go func() {
+		sc := bufio.NewScanner(resp.Body)
+		for sc.Scan() {
+			if line, ok := strings.CutPrefix(sc.Text(), "data: "); ok {
+				data <- line
+				return
+			}
+		}
+	}()
+
+	select {
+	case line := <-data:
+		if !strings.Contains(line, "notifications/tools/list_changed") {
+			t.Errorf("unexpected event data: %s", line)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for server-sent event")
+	}
+}
+
+func TestHTTPDeleteEndsSession(t *testing.T) {
+	disconnected := make(chan string, 1)
+	srv := httptest.NewServer(NewHTTPTransport(&mockSender{}, WithOnDisconnect(func(id string) {
+		disconnected <- id
+	})))
+	defer srv.Close()
+
+	id := initHTTPSession(t, srv.URL)
+
+	req, _ := http.NewRequest(http.MethodDelete, srv.URL, nil)
+	req.Header.Set(sessionHeader, id)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("DELETE: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("DELETE status: got %d, want %d", resp.StatusCode, http.StatusNoContent)
+	}
+
+	select {
+	case got := <-disconnected:
+		if got != id {
+			t.Errorf("onDisconnect session: got %q, want %q", got, id)
+		}
+	default:
+		t.Error("expected onDisconnect to be called")
+	}
+
+	if after := postJSON(t, srv.URL, id, `{"jsonrpc":"2.0","id":3,"method":"ping"}`); after.StatusCode != http.StatusNotFound {
+		t.Errorf("request after DELETE: got status %d, want %d", after.StatusCode, http.StatusNotFound)
+	}
+}
+
+func TestHTTPRejectsForeignOrigins(t *testing.T) {
+	srv := httptest.NewServer(NewHTTPTransport(&mockSender{}, WithAllowedOrigins("https://app.example.com")))
+	defer srv.Close()
+
+	body := `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-06-18"}}`
+	tests := []struct {
+		origin string
+		want   int
+	}{
+		{"", http.StatusOK},
+		{"http://localhost:3000", http.StatusOK},
+		{"http://127.0.0.1:8080", http.StatusOK},
+		{"http://[::1]", http.StatusOK},
+		{"https://app.example.com", http.StatusOK},
+		{"https://evil.example.com", http.StatusForbidden},
+		{"http://localhost.evil.example.com", http.StatusForbidden},
+		{"null", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest(http.MethodPost, srv.URL, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if tt.origin != "" {
+			req.Header.Set("Origin", tt.origin)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("POST: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tt.want {
+			t.Errorf("Origin %q: got status %d, want %d", tt.origin, resp.StatusCode, tt.want)
+		}
+	}
+}
+
+// deleteHTTPSession terminates a session with a DELETE request.
+func deleteHTTPSession(t *testing.T, url, id string) {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodDelete, url, nil)
+	req.Header.Set(sessionHeader, id)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("DELETE: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("DELETE status: got %d, want %d", resp.StatusCode, http.StatusNoContent)
+	}
+}
+
+func TestHTTPLimitsSessions(t *testing.T) {
+	srv := httptest.NewServer(NewHTTPTransport(&mockSender{}, WithMaxSessions(2)))
+	defer srv.Close()
+
+	first := initHTTPSession(t, srv.URL)
+	initHTTPSession(t, srv.URL)
+
+	resp := postJSON(t, srv.URL, "", `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`)
+	if resp.StatusCode != http.StatusServiceUnavailable || resp.Header.Get(sessionHeader) != "" {
+		t.Fatalf("initialize over the limit: got status %d, session %q", resp.StatusCode, resp.Header.Get(sessionHeader))
+	}
+	if rpc := decodeHTTPResponse(t, resp); rpc.Error == nil || rpc.Error.Code != codeServerBusy {
+		t.Errorf("expected a server busy error, got %+v", rpc.Error)
+	}
+
+	// Ending a session makes room for another.
+	deleteHTTPSession(t, srv.URL, first)
+	initHTTPSession(t, srv.URL)
+}
+
+func TestHTTPClosesIdleSessions(t *testing.T) {
+	h := NewHTTPTransport(&mockSender{}, WithSessionIdleTimeout(time.Minute))
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	idle := initHTTPSession(t, srv.URL)
+	streaming := initHTTPSession(t, srv.URL)
+	req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set(sessionHeader, streaming)
+	stream, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	defer stream.Body.Close()
+
+	h.closeIdle(time.Now())
+	if resp := postJSON(t, srv.URL, idle, `{"jsonrpc":"2.0","id":2,"method":"ping"}`); resp.StatusCode != http.StatusOK {
+		t.Fatalf("recently used session: got status %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+
+	// An hour later only the session with an open event stream is left.
+	h.closeIdle(time.Now().Add(time.Hour))
+	if resp := postJSON(t, srv.URL, idle, `{"jsonrpc":"2.0","id":3,"method":"ping"}`); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("idle session: got status %d, want %d", resp.StatusCode, http.StatusNotFound)
+	}
+	if resp := postJSON(t, srv.URL, streaming, `{"jsonrpc":"2.0","id":4,"method":"ping"}`); resp.StatusCode != http.StatusOK {
+		t.Errorf("streaming session: got status %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+}
+
+func TestHTTPDeleteFailsClientRequests(t *testing.T) {
+	sender, published := publishRecorder()
+	events := make(chan *pluginv1.EventDelivery, 1)
+	h := NewHTTPTransport(sender, WithEventChannel(events), WithClientRequestTimeout(0))
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go h.Run(ctx)
+
+	resp := postJSON(t, srv.URL, "", `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-06-18","capabilities":{"sampling":{}}}}`)
+	id := resp.Header.Get(sessionHeader)
+	postJSON(t, srv.URL, id, `{"jsonrpc":"2.0","method":"notifications/initialized"}`)
+
+	// The sampling request waits for a client that never answers.
+	events <- samplingEvent(t, map[string]any{"request_id": "ai-8", "session_id": id, "params": map[string]any{"maxTokens": 1}})
+	deadline := time.Now().Add(5 * time.Second)
+	for s, _ := h.lookup(id); ; {
+		s.t.clientMu.Lock()
+		pending := len(s.t.clientPending)
+		s.t.clientMu.Unlock()
+		if pending > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("sampling request never reached the session")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	deleteHTTPSession(t, srv.URL, id)
+	reply := waitPublished(t, published)
+	if msg, _ := reply["error"].(string); !strings.Contains(msg, errSessionClosed.Error()) {
+		t.Errorf("expected a session closed reply, got %v", reply)
+	}
+}
+
+func TestHTTPInvalidatesResourceCacheWithoutSessions(t *testing.T) {
+	var reads atomic.Int64
+	events := make(chan *pluginv1.EventDelivery)
+	h := NewHTTPTransport(countingStorage(&reads), WithEventChannel(events), WithResourceCache(ResourceCacheConfig{}))
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go h.Run(ctx)
+
+	read := func(id string) string {
+		t.Helper()
+		rpc := decodeHTTPResponse(t, postJSON(t, srv.URL, id, `{"jsonrpc":"2.0","id":2,"method":"resources/read","params":{"uri":"orchestra://features/FEAT-1"}}`))
+		if rpc.Error != nil {
+			t.Fatalf("read: %+v", rpc.Error)
+		}
+		return rpc.Result.(map[string]any)["contents"].([]any)[0].(map[string]any)["text"].(string)
+	}
+
+	first := initHTTPSession(t, srv.URL)
+	read(first)
+	deleteHTTPSession(t, srv.URL, first)
+
+	// The write happens while no session is open.
+	events <- versionedStorageEvent(t, "features/FEAT-1.md", 2)
+	deadline := time.Now().Add(5 * time.Second)
+	for h.resourceCache.snapshot().Invalidations == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("storage event never invalidated the cache")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if got := read(initHTTPSession(t, srv.URL)); got != "features/FEAT-1.md #2" {
+		t.Errorf("expected fresh content after the write, got %q", got)
+	}
+}
@@ -0,0 +1,98 @@
+package internal
+
+import (
+	"log/slog"
+
+	"github.com/orchestra-mcp/sdk-go/protocol"
+)
+
+// LifecycleState is the stage of the MCP session lifecycle a transport is in.
+type LifecycleState int32
+
+const (
+	// StateUninitialized: only initialize and ping are accepted.
+	StateUninitialized LifecycleState = iota
+	// StateInitializing: initialize succeeded; the client has not yet sent
+	// notifications/initialized.
+	StateInitializing
+	// StateReady: all methods are accepted.
+	StateReady
+	// StateShuttingDown: the session is closing and only ping is accepted.
+	StateShuttingDown
+)
+
+// String returns the state's name.
+func (s LifecycleState) String() string {
+	switch s {
+	case StateUninitialized:
+		return "uninitialized"
+	case StateInitializing:
+		return "initializing"
+	case StateReady:
+		return "ready"
+	case StateShuttingDown:
+		return "shutting down"
+	default:
+		return "unknown"
+	}
+}
+
+// State returns the transport's current lifecycle state.
+func (t *StdioTransport) State() LifecycleState {
+	return LifecycleState(t.state.Load())
+}
+
+// transition moves the lifecycle from one state to another, reporting false
+// if the transport was not in the from state.
+func (t *StdioTransport) transition(from, to LifecycleState) bool {
+	if !t.state.CompareAndSwap(int32(from), int32(to)) {
+		return false
+	}
+	slog.Debug("session state changed", "session", t.sessionID, "from", from, "to", to)
+	return true
+}
+
+// shutdown moves the transport to StateShuttingDown from any state.
+func (t *StdioTransport) shutdown() {
+	t.state.Store(int32(StateShuttingDown))
+}
+
+// admit checks a newly received request against the lifecycle state before it
+// is dispatched, returning false and the error response to send if the state
+// does not allow it. ping is always allowed and initialize is checked by its
+// handler. Rejected notifications are dropped, since they cannot be answered.
+// Requests admitted before shutdown began still run to completion.
+func (t *StdioTransport) admit(req *protocol.JSONRPCRequest) (*protocol.JSONRPCResponse, bool) {
+	state := t.State()
+	switch {
+	case req.Method == "ping" || req.Method == "initialize" || state == StateReady:
+		return nil, true
+	case req.Method == "notifications/initialized" && state == StateInitializing:
+		return nil, true
+	}
+
+	slog.Debug("request rejected by session state", "method", req.Method, "state", state)
+	if req.ID == nil {
+		return nil, false
+	}
+	msg := "server not initialized: send initialize and notifications/initialized first"
+	if state == StateShuttingDown {
+		msg = "server is shutting down"
+	}
+	return &protocol.JSONRPCResponse{
+		JSONRPC: "2.0",
+		ID:      req.ID,
+		Error: &protocol.JSONRPCError{
+			Code:    protocol.InvalidRequest,
+			Message: msg,
+		},
+	}, false
+}
+
+// handleInitialized completes the handshake when the client confirms it, then
+// asks the client for its roots.
+func (t *StdioTransport) handleInitialized() {
+	if t.transition(StateInitializing, StateReady) {
+		t.refreshRoots()
+	}
+}
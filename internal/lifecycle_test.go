@@ -0,0 +1,85 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/orchestra-mcp/sdk-go/protocol"
+)
+
+func TestLifecycleRejectsRequestsBeforeInitialized(t *testing.T) {
+	input := strings.Join([]string{
+		`{"jsonrpc":"2.0","id":1,"method":"tools/list"}`,
+		`{"jsonrpc":"2.0","id":2,"method":"ping"}`,
+		`{"jsonrpc":"2.0","id":3,"method":"initialize","params":{}}`,
+		`{"jsonrpc":"2.0","id":4,"method":"logging/setLevel","params":{"level":"debug"}}`,
+		`{"jsonrpc":"2.0","method":"notifications/initialized"}`,
+		`{"jsonrpc":"2.0","id":5,"method":"logging/setLevel","params":{"level":"debug"}}`,
+	}, "\n") + "\n"
+
+	var out bytes.Buffer
+	tr := NewStdioTransport(&mockSender{}, strings.NewReader(input), &out)
+	if tr.State() != StateUninitialized {
+		t.Fatalf("initial state: got %v", tr.State())
+	}
+	if err := tr.Run(context.Background()); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 5 {
+		t.Fatalf("expected 5 responses, got %d: %s", len(lines), out.String())
+	}
+	wantErr := map[float64]bool{1: true, 2: false, 3: false, 4: true, 5: false}
+	for _, line := range lines {
+		resp := parseJSONRPCResponse(t, line)
+		id := resp.ID.(float64)
+		if got := resp.Error != nil; got != wantErr[id] {
+			t.Errorf("request %v: got error %+v, want error %v", id, resp.Error, wantErr[id])
+		}
+		if resp.Error != nil && resp.Error.Code != protocol.InvalidRequest {
+			t.Errorf("request %v: got code %d, want %d", id, resp.Error.Code, protocol.InvalidRequest)
+		}
+	}
+	if tr.State() != StateShuttingDown {
+		t.Errorf("state after Run: got %v, want %v", tr.State(), StateShuttingDown)
+	}
+}
+
+func TestLifecycleRejectsReinitialize(t *testing.T) {
+	tr := NewStdioTransport(&mockSender{}, nil, nil)
+	handshake(t, tr)
+	if tr.State() != StateReady {
+		t.Fatalf("state after handshake: got %v, want %v", tr.State(), StateReady)
+	}
+	session := tr.sessionID
+
+	resp := initializeWith(t, tr, "2025-06-18")
+	if resp.Error == nil || resp.Error.Code != protocol.InvalidRequest {
+		t.Fatalf("expected InvalidRequest for a second initialize, got %+v", resp)
+	}
+	if tr.sessionID != session {
+		t.Errorf("session ID changed from %q to %q", session, tr.sessionID)
+	}
+}
+
+func TestLifecycleStates(t *testing.T) {
+	tr := NewStdioTransport(&mockSender{}, nil, nil)
+	initializeWith(t, tr, "2025-06-18")
+	if tr.State() != StateInitializing {
+		t.Fatalf("state after initialize: got %v, want %v", tr.State(), StateInitializing)
+	}
+	if resp, ok := tr.admit(&protocol.JSONRPCRequest{JSONRPC: "2.0", ID: 1, Method: "tools/list"}); ok || resp == nil {
+		t.Errorf("expected tools/list to be rejected while initializing")
+	}
+
+	tr.shutdown()
+	if resp, ok := tr.admit(&protocol.JSONRPCRequest{JSONRPC: "2.0", ID: 2, Method: "tools/list"}); ok || !strings.Contains(resp.Error.Message, "shutting down") {
+		t.Errorf("expected tools/list to be rejected while shutting down, got %+v", resp)
+	}
+	if _, ok := tr.admit(&protocol.JSONRPCRequest{JSONRPC: "2.0", ID: 3, Method: "ping"}); !ok {
+		t.Error("expected ping to be allowed while shutting down")
+	}
+}
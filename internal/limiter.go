@@ -0,0 +1,201 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// codeServerBusy is the JSON-RPC error code returned when a tools/call is
+// rejected because the call queue is full. It is in the range JSON-RPC
+// reserves for implementation-defined server errors.
+const codeServerBusy = -32000
+
+// errCallQueueFull is returned when a tools/call cannot run and the queue of
+// waiting calls is at its maximum depth.
+var errCallQueueFull = errors.New("server busy: too many concurrent tool calls")
+
+// CallLimits bounds concurrent tools/call dispatch. A zero limit means
+// unlimited.
+type CallLimits struct {
+	MaxInFlight        int            // concurrent calls across all tools
+	MaxInFlightPerTool int            // concurrent calls per tool name
+	PerTool            map[string]int // per-tool overrides of MaxInFlightPerTool
+	MaxQueue           int            // calls waiting for a slot; 0 rejects when at a limit
+}
+
+// CallStats reports queueing of tools/call requests.
+type CallStats struct {
+	InFlight  int           // calls currently running
+	Queued    int           // calls currently waiting for a slot
+	Waited    int64         // calls that had to wait before running
+	Rejected  int64         // calls rejected because the queue was full
+	TotalWait time.Duration // summed queue wait of calls that waited
+	MaxWait   time.Duration // longest queue wait observed
+}
+
+// callLimiter admits tools/call requests subject to CallLimits. Calls that
+// cannot run immediately wait in FIFO order; a call blocked only by its own
+// tool's limit does not hold up calls to other tools.
+type callLimiter struct {
+	limits CallLimits
+
+	mu       sync.Mutex
+	inFlight int
+	perTool  map[string]int
+	queue    []*callWaiter
+	stats    CallStats
+}
+
+// callWaiter is a queued call. ready is closed once it has been admitted.
+type callWaiter struct {
+	tool  string
+	ready chan struct{}
+}
+
+func newCallLimiter(limits CallLimits) *callLimiter {
+	return &callLimiter{
+		limits:  limits,
+		perTool: make(map[string]int),
+	}
+}
+
+// WithCallLimits bounds concurrent tools/call dispatch. Transports created with
+// the same option value share the limits, so an HTTP endpoint enforces them
+// across all of its sessions.
+func WithCallLimits(limits CallLimits) func(*StdioTransport) {
+	l := newCallLimiter(limits)
+	return func(t *StdioTransport) {
+		t.limiter = l
+	}
+}
+
+// CallStats returns the transport's tools/call queueing statistics.
+func (t *StdioTransport) CallStats() CallStats {
+	if t.limiter == nil {
+		return CallStats{}
+	}
+	return t.limiter.snapshot()
+}
+
+// acquire waits for a slot to run a call to tool. It fails with
+// errCallQueueFull if the call would have to wait and the queue is full, or
+// with the context's error if ctx ends while waiting. The returned release
+// func must be called when the call completes.
+func (l *callLimiter) acquire(ctx context.Context, tool string) (func(), error) {
+	release := func() { l.release(tool) }
+
+	l.mu.Lock()
+	if l.admissible(tool) {
+		l.admit(tool)
+		l.mu.Unlock()
+		return release, nil
+	}
+	if len(l.queue) >= l.limits.MaxQueue {
+		l.stats.Rejected++
+		l.mu.Unlock()
+		return nil, errCallQueueFull
+	}
+	w := &callWaiter{tool: tool, ready: make(chan struct{})}
+	l.queue = append(l.queue, w)
+	l.mu.Unlock()
+
+	start := time.Now()
+	select {
+	case <-w.ready:
+		l.recordWait(tool, time.Since(start))
+		return release, nil
+	case <-ctx.Done():
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		select {
+		case <-w.ready:
+			// Admitted concurrently with cancellation; give the slot back.
+			l.releaseLocked(tool)
+		default:
+			l.remove(w)
+		}
+		return nil, context.Cause(ctx)
+	}
+}
+
+// release frees a slot held by a call to tool and admits waiting calls.
+func (l *callLimiter) release(tool string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.releaseLocked(tool)
+}
+
+func (l *callLimiter) releaseLocked(tool string) {
+	l.inFlight--
+	if l.perTool[tool]--; l.perTool[tool] <= 0 {
+		delete(l.perTool, tool)
+	}
+
+	// Admit queued calls in order, skipping those still blocked by their
+	// tool's limit.
+	kept := l.queue[:0]
+	for _, w := range l.queue {
+		if l.admissible(w.tool) {
+			l.admit(w.tool)
+			close(w.ready)
+			continue
+		}
+		kept = append(kept, w)
+	}
+	clear(l.queue[len(kept):])
+	l.queue = kept
+}
+
+// admissible reports whether a call to tool may start now. The caller must
+// hold l.mu.
+func (l *callLimiter) admissible(tool string) bool {
+	if l.limits.MaxInFlight > 0 && l.inFlight >= l.limits.MaxInFlight {
+		return false
+	}
+	limit := l.limits.MaxInFlightPerTool
+	if n, ok := l.limits.PerTool[tool]; ok {
+		limit = n
+	}
+	return limit <= 0 || l.perTool[tool] < limit
+}
+
+// admit counts a call to tool as running. The caller must hold l.mu.
+func (l *callLimiter) admit(tool string) {
+	l.inFlight++
+	l.perTool[tool]++
+}
+
+// remove drops a waiter from the queue. The caller must hold l.mu.
+func (l *callLimiter) remove(w *callWaiter) {
+	for i, q := range l.queue {
+		if q == w {
+			l.queue = append(l.queue[:i], l.queue[i+1:]...)
+			return
+		}
+	}
+}
+
+// recordWait adds a completed queue wait to the statistics.
+func (l *callLimiter) recordWait(tool string, d time.Duration) {
+	l.mu.Lock()
+	l.stats.Waited++
+	l.stats.TotalWait += d
+	if d > l.stats.MaxWait {
+		l.stats.MaxWait = d
+	}
+	l.mu.Unlock()
+	slog.Debug("tool call waited for a slot", "tool", tool, "wait", d)
+}
+
+// snapshot returns the current statistics.
+func (l *callLimiter) snapshot() CallStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	s := l.stats
+	s.InFlight = l.inFlight
+	s.Queued = len(l.queue)
+	return s
+}
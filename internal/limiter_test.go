@@ -0,0 +1,142 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/orchestra-mcp/sdk-go/protocol"
+)
+
+// acquireAsync starts acquire in a goroutine and returns a channel that
+// receives its result.
+func acquireAsync(ctx context.Context, l *callLimiter, tool string) <-chan error {
+	ch := make(chan error, 1)
+	go func() {
+		_, err := l.acquire(ctx, tool)
+		ch <- err
+	}()
+	return ch
+}
+
+// waitQueued waits until n calls are queued on the limiter.
+func waitQueued(t *testing.T, l *callLimiter, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for l.snapshot().Queued != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %d queued calls, have %d", n, l.snapshot().Queued)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestCallLimiterQueuesAndRejects(t *testing.T) {
+	l := newCallLimiter(CallLimits{MaxInFlight: 1, MaxQueue: 1})
+	ctx := context.Background()
+
+	release, err := l.acquire(ctx, "a")
+	if err != nil {
+		t.Fatalf("first acquire: %v", err)
+	}
+	queued := acquireAsync(ctx, l, "b")
+	waitQueued(t, l, 1)
+
+	if _, err := l.acquire(ctx, "c"); !errors.Is(err, errCallQueueFull) {
+		t.Fatalf("expected errCallQueueFull, got %v", err)
+	}
+
+	release()
+	select {
+	case err := <-queued:
+		if err != nil {
+			t.Fatalf("queued acquire: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("queued call was not admitted after release")
+	}
+
+	s := l.snapshot()
+	if s.InFlight != 1 || s.Queued != 0 || s.Waited != 1 || s.Rejected != 1 {
+		t.Errorf("unexpected stats: %+v", s)
+	}
+	if s.TotalWait <= 0 || s.MaxWait != s.TotalWait {
+		t.Errorf("expected wait time to be recorded: %+v", s)
+	}
+}
+
+func TestCallLimiterPerTool(t *testing.T) {
+	l := newCallLimiter(CallLimits{
+		MaxInFlightPerTool: 2,
+		PerTool:            map[string]int{"slow": 1},
+		MaxQueue:           4,
+	})
+	ctx := context.Background()
+
+	if _, err := l.acquire(ctx, "slow"); err != nil {
+		t.Fatalf("acquire slow: %v", err)
+	}
+	queued := acquireAsync(ctx, l, "slow")
+	waitQueued(t, l, 1)
+
+	// Other tools are not held up by the queued slow call.
+	for range 2 {
+		if _, err := l.acquire(ctx, "fast"); err != nil {
+			t.Fatalf("acquire fast: %v", err)
+		}
+	}
+	select {
+	case err := <-queued:
+		t.Fatalf("slow call admitted past its limit: %v", err)
+	default:
+	}
+	if s := l.snapshot(); s.InFlight != 3 || s.Queued != 1 {
+		t.Errorf("unexpected stats: %+v", s)
+	}
+}
+
+func TestCallLimiterCancelWhileQueued(t *testing.T) {
+	l := newCallLimiter(CallLimits{MaxInFlight: 1, MaxQueue: 1})
+
+	release, _ := l.acquire(context.Background(), "a")
+	ctx, cancel := context.WithCancel(context.Background())
+	queued := acquireAsync(ctx, l, "b")
+	waitQueued(t, l, 1)
+
+	cancel()
+	if err := <-queued; !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if s := l.snapshot(); s.Queued != 0 {
+		t.Errorf("cancelled call still queued: %+v", s)
+	}
+
+	release()
+	if s := l.snapshot(); s.InFlight != 0 {
+		t.Errorf("expected no calls in flight, got %+v", s)
+	}
+}
+
+func TestToolsCallServerBusy(t *testing.T) {
+	tr := newReadyTransport(t, &mockSender{}, nil, nil, WithCallLimits(CallLimits{MaxInFlight: 1}))
+	release, err := tr.limiter.acquire(context.Background(), "busy")
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	defer release()
+
+	resp := tr.dispatch(context.Background(), &protocol.JSONRPCRequest{
+		JSONRPC: "2.0",
+		ID:      1,
+		Method:  "tools/call",
+		Params:  json.RawMessage(`{"name":"echo"}`),
+	})
+	if resp.Error == nil || resp.Error.Code != codeServerBusy {
+		t.Fatalf("expected server busy error, got %+v", resp.Error)
+	}
+	if s := tr.CallStats(); s.Rejected != 1 {
+		t.Errorf("expected one rejected call, got %+v", s)
+	}
+}
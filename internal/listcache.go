@@ -0,0 +1,272 @@
+package internal
+
+import (
+	"context"
+	"log/slog"
+	"reflect"
+	"sync"
+	"time"
+
+	pluginv1 "github.com/orchestra-mcp/gen-go/orchestra/plugin/v1"
+)
+
+// Plugin lifecycle topics published by the orchestrator when a plugin joins or
+// leaves, changing the tools and prompts it serves:
+//
+//	{"plugin_id": "tools.features"}
+const (
+	pluginRegisteredTopic   = "plugin.registered"
+	pluginUnregisteredTopic = "plugin.unregistered"
+)
+
+// listRefreshTimeout bounds the ListTools and ListPrompts calls made to
+// compare the lists after a plugin event.
+const listRefreshTimeout = 30 * time.Second
+
+// listCache holds the orchestrator's latest ListTools and ListPrompts
+// responses, so repeated tools/list and prompts/list requests, and their
+// pages, are answered without asking the orchestrator again. Only transports
+// with an event channel cache the lists, since plugin events are what keeps
+// them current.
+type listCache struct {
+	mu         sync.Mutex
+	tools      *pluginv1.ListToolsResponse   // nil until listed
+	prompts    *pluginv1.ListPromptsResponse // nil until listed
+	epoch      uint64                        // counts invalidations; see listTools
+	refreshing bool                          // a refresh worker is running
+	pending    listRefresh                   // work for the refresh worker
+}
+
+// listRefresh is the lists a refresh compares with fresh ones, as the client
+// last saw them, and the plugin event topic that prompted it.
+type listRefresh struct {
+	topic   string
+	tools   *pluginv1.ListToolsResponse
+	prompts *pluginv1.ListPromptsResponse
+}
+
+// listTools returns the orchestrator's tools, from the cache when possible.
+// See listCache for when lists are cached.
+// A nil response without an error means the orchestrator answered with
+// another response type.
+func (t *StdioTransport) listTools(ctx context.Context, id any) (*pluginv1.ListToolsResponse, error) {
+	c := &t.lists
+	c.mu.Lock()
+	lt, epoch := c.tools, c.epoch
+	c.mu.Unlock()
+	if lt != nil {
+		return lt, nil
+	}
+
+	resp, err := t.send(ctx, &pluginv1.PluginRequest{
+		RequestId: t.requestID("lt", id),
+		Request: &pluginv1.PluginRequest_ListTools{
+			ListTools: &pluginv1.ListToolsRequest{},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	lt = resp.GetListTools()
+	if lt != nil && t.eventCh != nil {
+		// A response that overlaps an invalidation may predate the change.
+		c.mu.Lock()
+		if c.epoch == epoch {
+			c.tools = lt
+		}
+		c.mu.Unlock()
+	}
+	return lt, nil
+}
+
+// listPrompts returns the orchestrator's prompts, from the cache when
+// possible. A nil response without an error means the orchestrator answered
+// with another response type.
+func (t *StdioTransport) listPrompts(ctx context.Context, id any) (*pluginv1.ListPromptsResponse, error) {
+	c := &t.lists
+	c.mu.Lock()
+	lp, epoch := c.prompts, c.epoch
+	c.mu.Unlock()
+	if lp != nil {
+		return lp, nil
+	}
+
+	resp, err := t.send(ctx, &pluginv1.PluginRequest{
+		RequestId: t.requestID("lp", id),
+		Request: &pluginv1.PluginRequest_ListPrompts{
+			ListPrompts: &pluginv1.ListPromptsRequest{},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	lp = resp.GetListPrompts()
+	if lp != nil && t.eventCh != nil {
+		c.mu.Lock()
+		if c.epoch == epoch {
+			c.prompts = lp
+		}
+		c.mu.Unlock()
+	}
+	return lp, nil
+}
+
+// invalidateLists drops the cached lists and returns them.
+func (t *StdioTransport) invalidateLists() (*pluginv1.ListToolsResponse, *pluginv1.ListPromptsResponse) {
+	c := &t.lists
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	tools, prompts := c.tools, c.prompts
+	c.tools, c.prompts = nil, nil
+	c.epoch++
+	return tools, prompts
+}
+
+// dropTools drops the cached tool list.
+func (c *listCache) dropTools() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.tools = nil
+	c.epoch++
+}
+
+// dropPrompts drops the cached prompt list.
+func (c *listCache) dropPrompts() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.prompts = nil
+	c.epoch++
+}
+
+// handlePluginEvent drops the cached lists when a plugin registers or
+// unregisters. Lists the client has already seen are compared with fresh ones
+// by a single background worker, so refreshes never overlap: events arriving
+// while it runs are merged into its next round, which keeps the oldest lists
+// as the baseline. The worker counts as background work of the session.
+func (t *StdioTransport) handlePluginEvent(ev *pluginv1.EventDelivery) {
+	tools, prompts := t.invalidateLists()
+	c := &t.lists
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.pending.tools == nil {
+		c.pending.tools = tools
+	}
+	if c.pending.prompts == nil {
+		c.pending.prompts = prompts
+	}
+	if c.pending.tools == nil && c.pending.prompts == nil {
+		return
+	}
+	c.pending.topic = ev.GetTopic()
+	if c.refreshing {
+		return
+	}
+	if !t.background(t.refreshWorker) {
+		c.pending = listRefresh{}
+		return
+	}
+	c.refreshing = true
+}
+
+// refreshWorker runs pending refreshes one at a time until none is left.
+// When a plugin event arrives during a refresh, the lists it fetched may
+// predate the change, so they become the baseline of the next round.
+func (t *StdioTransport) refreshWorker() {
+	c := &t.lists
+	for {
+		c.mu.Lock()
+		r, epoch := c.pending, c.epoch
+		c.pending = listRefresh{}
+		if r.tools == nil && r.prompts == nil {
+			c.refreshing = false
+			c.mu.Unlock()
+			return
+		}
+		c.mu.Unlock()
+
+		tools, prompts := t.refreshLists(r.topic, r.tools, r.prompts)
+
+		c.mu.Lock()
+		if c.epoch != epoch {
+			if c.pending.tools == nil {
+				c.pending.tools = tools
+			}
+			if c.pending.prompts == nil {
+				c.pending.prompts = prompts
+			}
+			if c.pending.topic == "" {
+				c.pending.topic = r.topic
+			}
+		}
+		c.mu.Unlock()
+	}
+}
+
+// refreshLists fetches the lists that were cached before a plugin event and
+// sends notifications/tools/list_changed or notifications/prompts/list_changed
+// for those that differ. A list that cannot be fetched is reported as
+// changed, since the plugin event suggests it did. It returns the lists it
+// fetched, nil for those it did not.
+func (t *StdioTransport) refreshLists(topic string, tools *pluginv1.ListToolsResponse, prompts *pluginv1.ListPromptsResponse) (*pluginv1.ListToolsResponse, *pluginv1.ListPromptsResponse) {
+	ctx, cancel := context.WithTimeout(context.Background(), listRefreshTimeout)
+	defer cancel()
+	id := "refresh-" + topic
+
+	var lt *pluginv1.ListToolsResponse
+	if tools != nil {
+		var err error
+		lt, err = t.listTools(ctx, id)
+		if err != nil || lt == nil || !sameTools(tools.GetTools(), lt.GetTools()) {
+			slog.Debug("tool list changed", "topic", topic, "error", err)
+			t.writeNotification("notifications/tools/list_changed", nil)
+		}
+	}
+	var lp *pluginv1.ListPromptsResponse
+	if prompts != nil {
+		var err error
+		lp, err = t.listPrompts(ctx, id)
+		if err != nil || lp == nil || !samePrompts(prompts.GetPrompts(), lp.GetPrompts()) {
+			slog.Debug("prompt list changed", "topic", topic, "error", err)
+			t.writeNotification("notifications/prompts/list_changed", nil)
+		}
+	}
+	return lt, lp
+}
+
+// sameTools reports whether two tool lists hold the same definitions,
+// regardless of order.
+func sameTools(a, b []*pluginv1.ToolDefinition) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	defs := make(map[string]ToolDefinition, len(a))
+	for _, td := range a {
+		defs[td.GetName()] = ToolDefinitionToMCP(td)
+	}
+	for _, td := range b {
+		def, ok := defs[td.GetName()]
+		if !ok || !reflect.DeepEqual(def, ToolDefinitionToMCP(td)) {
+			return false
+		}
+	}
+	return true
+}
+
+// samePrompts reports whether two prompt lists hold the same definitions,
+// regardless of order.
+func samePrompts(a, b []*pluginv1.PromptDefinition) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	defs := make(map[string]any, len(a))
+	for _, pd := range a {
+		defs[pd.GetName()] = PromptDefinitionToMCP(pd)
+	}
+	for _, pd := range b {
+		def, ok := defs[pd.GetName()]
+		if !ok || !reflect.DeepEqual(def, PromptDefinitionToMCP(pd)) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,205 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"sync"
+	"testing"
+
+	pluginv1 "github.com/orchestra-mcp/gen-go/orchestra/plugin/v1"
+	"github.com/orchestra-mcp/sdk-go/protocol"
+)
+
+// registry is an orchestrator whose tools and prompts can change, counting
+// the list requests it answers.
+type registry struct {
+	mu          sync.Mutex
+	tools       []*pluginv1.ToolDefinition
+	prompts     []*pluginv1.PromptDefinition
+	toolLists   int
+	promptLists int
+}
+
+func (r *registry) set(tools []*pluginv1.ToolDefinition, prompts []*pluginv1.PromptDefinition) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.tools, r.prompts = tools, prompts
+}
+
+func (r *registry) counts() (int, int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.toolLists, r.promptLists
+}
+
+func (r *registry) sender() *mockSender {
+	return &mockSender{
+		sendFunc: func(ctx context.Context, req *pluginv1.PluginRequest) (*pluginv1.PluginResponse, error) {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			if req.GetListPrompts() != nil {
+				r.promptLists++
+				return &pluginv1.PluginResponse{
+					Response: &pluginv1.PluginResponse_ListPrompts{
+						ListPrompts: &pluginv1.ListPromptsResponse{Prompts: r.prompts},
+					},
+				}, nil
+			}
+			r.toolLists++
+			return &pluginv1.PluginResponse{
+				Response: &pluginv1.PluginResponse_ListTools{
+					ListTools: &pluginv1.ListToolsResponse{Tools: r.tools},
+				},
+			}, nil
+		},
+	}
+}
+
+// withEvents gives a transport an event channel, which enables the list cache.
+func withEvents() func(*StdioTransport) {
+	return WithEventChannel(make(chan *pluginv1.EventDelivery))
+}
+
+func listBoth(t *testing.T, tr *StdioTransport) {
+	t.Helper()
+	for _, method := range []string{"tools/list", "prompts/list"} {
+		resp := tr.dispatch(context.Background(), &protocol.JSONRPCRequest{JSONRPC: "2.0", ID: 1, Method: method})
+		if resp.Error != nil {
+			t.Fatalf("%s: %+v", method, resp.Error)
+		}
+	}
+}
+
+func TestListsAreCached(t *testing.T) {
+	reg := &registry{}
+	reg.set([]*pluginv1.ToolDefinition{{Name: "a"}}, []*pluginv1.PromptDefinition{{Name: "p"}})
+	tr := newReadyTransport(t, reg.sender(), nil, nil, withEvents())
+
+	listBoth(t, tr)
+	listBoth(t, tr)
+	if tools, prompts := reg.counts(); tools != 1 || prompts != 1 {
+		t.Errorf("expected one ListTools and one ListPrompts, got %d and %d", tools, prompts)
+	}
+
+	// A plugin event before the lists were ever seen only drops the cache.
+	tr.invalidateLists()
+	tr.handlePluginEvent(&pluginv1.EventDelivery{Topic: pluginRegisteredTopic})
+	listBoth(t, tr)
+	if tools, prompts := reg.counts(); tools != 2 || prompts != 2 {
+		t.Errorf("expected the lists to be fetched again, got %d and %d", tools, prompts)
+	}
+}
+
+func TestListsNotCachedWithoutEvents(t *testing.T) {
+	reg := &registry{}
+	reg.set([]*pluginv1.ToolDefinition{{Name: "a"}}, nil)
+	tr := newReadyTransport(t, reg.sender(), nil, nil)
+
+	// Nothing would tell the transport that the lists changed.
+	listBoth(t, tr)
+	reg.set([]*pluginv1.ToolDefinition{{Name: "a"}, {Name: "b"}}, nil)
+	listBoth(t, tr)
+	if tools, prompts := reg.counts(); tools != 2 || prompts != 2 {
+		t.Errorf("expected every list request to reach the orchestrator, got %d and %d", tools, prompts)
+	}
+	resp := roundTrip(t, tr.dispatch(context.Background(), &protocol.JSONRPCRequest{JSONRPC: "2.0", ID: 2, Method: "tools/list"}))
+	if n := len(resp.Result.(map[string]any)["tools"].([]any)); n != 2 {
+		t.Errorf("expected the newly registered tool, got %d tools", n)
+	}
+}
+
+func TestPluginEventSendsListChanged(t *testing.T) {
+	reg := &registry{}
+	reg.set([]*pluginv1.ToolDefinition{{Name: "a"}}, []*pluginv1.PromptDefinition{{Name: "p"}})
+	out := newLineWriter()
+	tr := newReadyTransport(t, reg.sender(), nil, out, withEvents())
+	listBoth(t, tr)
+
+	reg.set([]*pluginv1.ToolDefinition{{Name: "a"}, {Name: "b"}}, []*pluginv1.PromptDefinition{{Name: "p"}})
+	if err := tr.handleEvent(&pluginv1.EventDelivery{Topic: pluginRegisteredTopic}); err != nil {
+		t.Fatalf("handleEvent: %v", err)
+	}
+
+	// The event itself is forwarded, and the tool list has changed.
+	var methods []string
+	for range 2 {
+		line := out.next(t)
+		for _, m := range []string{"notifications/event", "notifications/tools/list_changed", "notifications/prompts/list_changed"} {
+			if strings.Contains(line, `"`+m+`"`) {
+				methods = append(methods, m)
+			}
+		}
+	}
+	if !strings.Contains(strings.Join(methods, " "), "notifications/tools/list_changed") || strings.Contains(strings.Join(methods, " "), "prompts") {
+		t.Errorf("unexpected notifications: %v", methods)
+	}
+}
+
+func TestRefreshListsSkipsUnchangedLists(t *testing.T) {
+	reg := &registry{}
+	reg.set([]*pluginv1.ToolDefinition{{Name: "a"}, {Name: "b"}}, []*pluginv1.PromptDefinition{{Name: "p"}})
+	var out bytes.Buffer
+	tr := newReadyTransport(t, reg.sender(), nil, &out, withEvents())
+	listBoth(t, tr)
+
+	// The same definitions in another order are not a change.
+	reg.set([]*pluginv1.ToolDefinition{{Name: "b"}, {Name: "a"}}, []*pluginv1.PromptDefinition{{Name: "p", Description: "new"}})
+	tools, prompts := tr.invalidateLists()
+	tr.refreshLists(pluginUnregisteredTopic, tools, prompts)
+
+	if got := out.String(); strings.Contains(got, "tools/list_changed") || !strings.Contains(got, "notifications/prompts/list_changed") {
+		t.Errorf("expected only prompts/list_changed, got %q", got)
+	}
+	if tools, prompts := reg.counts(); tools != 2 || prompts != 2 {
+		t.Errorf("expected each list to be fetched again once, got %d and %d", tools, prompts)
+	}
+
+	// The refreshed lists are cached.
+	listBoth(t, tr)
+	if tools, prompts := reg.counts(); tools != 2 || prompts != 2 {
+		t.Errorf("expected the refreshed lists to be cached, got %d and %d", tools, prompts)
+	}
+}
+
+func TestPluginEventsDuringRefreshAreCoalesced(t *testing.T) {
+	reg := &registry{}
+	reg.set([]*pluginv1.ToolDefinition{{Name: "a"}}, []*pluginv1.PromptDefinition{{Name: "p"}})
+	started, release := make(chan struct{}), make(chan struct{})
+	var once sync.Once
+	sender := reg.sender()
+	list := sender.sendFunc
+	sender.sendFunc = func(ctx context.Context, req *pluginv1.PluginRequest) (*pluginv1.PluginResponse, error) {
+		if strings.Contains(req.GetRequestId(), "-refresh-") {
+			once.Do(func() {
+				close(started)
+				<-release
+			})
+		}
+		return list(ctx, req)
+	}
+	var out bytes.Buffer
+	tr := newReadyTransport(t, sender, nil, &out, withEvents())
+	listBoth(t, tr)
+
+	reg.set([]*pluginv1.ToolDefinition{{Name: "a"}, {Name: "b"}}, []*pluginv1.PromptDefinition{{Name: "p"}})
+	tr.handlePluginEvent(&pluginv1.EventDelivery{Topic: pluginRegisteredTopic})
+	<-started
+	// A second plugin joins while the first refresh is fetching the tools.
+	reg.set([]*pluginv1.ToolDefinition{{Name: "a"}, {Name: "b"}, {Name: "c"}}, []*pluginv1.PromptDefinition{{Name: "p"}})
+	tr.handlePluginEvent(&pluginv1.EventDelivery{Topic: pluginRegisteredTopic})
+	close(release)
+	tr.wg.Wait()
+
+	if n := strings.Count(out.String(), "notifications/tools/list_changed"); n != 1 || strings.Contains(out.String(), "prompts/list_changed") {
+		t.Errorf("expected a single tools/list_changed, got %q", out.String())
+	}
+	// The second round fetches the tools again, since the first fetch may
+	// predate the second event; the prompts fetched after it were cached.
+	if tools, prompts := reg.counts(); tools != 3 || prompts != 2 {
+		t.Errorf("expected two refresh rounds, got %d and %d list requests", tools, prompts)
+	}
+	if tr.lists.refreshing {
+		t.Error("refresh worker still marked as running")
+	}
+}
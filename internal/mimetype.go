@@ -0,0 +1,96 @@
+package internal
+
+import (
+	"bytes"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"unicode/utf8"
+
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// extensionMimeTypes maps file extensions to mime types. It takes precedence
+// over the system mime table so resource types do not depend on the host.
+var extensionMimeTypes = map[string]string{
+	".md":   "text/markdown",
+	".txt":  "text/plain",
+	".csv":  "text/csv",
+	".html": "text/html",
+	".css":  "text/css",
+	".js":   "text/javascript",
+	".json": "application/json",
+	".yaml": "application/yaml",
+	".yml":  "application/yaml",
+	".xml":  "application/xml",
+	".png":  "image/png",
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".gif":  "image/gif",
+	".webp": "image/webp",
+	".svg":  "image/svg+xml",
+	".pdf":  "application/pdf",
+	".zip":  "application/zip",
+}
+
+// metadataMimeTypeKeys are the storage metadata fields that may hold an
+// entry's mime type, in order of preference.
+var metadataMimeTypeKeys = []string{"mime_type", "content_type"}
+
+// mimeTypeForExtension returns the mime type of a file extension such as
+// ".png", or "" if it is unknown.
+func mimeTypeForExtension(ext string) string {
+	ext = strings.ToLower(ext)
+	if t, ok := extensionMimeTypes[ext]; ok {
+		return t
+	}
+	return baseMimeType(mime.TypeByExtension(ext))
+}
+
+// hasKnownExtension reports whether name ends in an extension with a known
+// mime type.
+func hasKnownExtension(name string) bool {
+	return mimeTypeForExtension(path.Ext(name)) != ""
+}
+
+// resourceMimeType determines the mime type of a stored entry from, in order,
+// the storage metadata, the entry's extension, the namespace and finally the
+// content itself.
+func resourceMimeType(ns ResourceNamespace, storagePath string, metadata *structpb.Struct, content []byte) string {
+	for _, key := range metadataMimeTypeKeys {
+		if t := baseMimeType(metadata.GetFields()[key].GetStringValue()); t != "" {
+			return t
+		}
+	}
+	if t := mimeTypeForExtension(path.Ext(storagePath)); t != "" {
+		return t
+	}
+	if ns.MimeType != "" {
+		return ns.MimeType
+	}
+	return baseMimeType(http.DetectContentType(content))
+}
+
+// baseMimeType strips parameters such as "; charset=utf-8" from a mime type.
+func baseMimeType(t string) string {
+	base, _, _ := strings.Cut(t, ";")
+	return strings.TrimSpace(base)
+}
+
+// isTextContent reports whether content of the given mime type can be returned
+// as resource text. Content that is not valid UTF-8 or holds NUL bytes is
+// binary whatever its declared type.
+func isTextContent(mimeType string, content []byte) bool {
+	if !utf8.Valid(content) || bytes.IndexByte(content, 0) >= 0 {
+		return false
+	}
+	if strings.HasPrefix(mimeType, "text/") || strings.HasSuffix(mimeType, "+json") || strings.HasSuffix(mimeType, "+xml") {
+		return true
+	}
+	switch mimeType {
+	case "application/json", "application/xml", "application/yaml", "application/javascript":
+		return true
+	}
+	return false
+}
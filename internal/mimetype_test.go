@@ -0,0 +1,83 @@
+package internal
+
+import (
+	"context"
+	"encoding/base64"
+	"testing"
+
+	pluginv1 "github.com/orchestra-mcp/gen-go/orchestra/plugin/v1"
+	"github.com/orchestra-mcp/sdk-go/protocol"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// pngHeader is the start of a PNG file, enough for content sniffing.
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+func TestResourcesReadContentTypes(t *testing.T) {
+	type entry struct {
+		content  []byte
+		metadata map[string]any
+	}
+	entries := map[string]entry{
+		"docs/guide.md":        {content: []byte("# Guide")},
+		"docs/diagram.png":     {content: pngHeader},
+		"docs/broken.md":       {content: []byte{0xff, 0xfe, 'h', 'i'}},
+		"docs/report.pdf":      {content: []byte("%PDF-1.7"), metadata: map[string]any{"mime_type": "application/x-report; version=2"}},
+		"fixtures/blob":        {content: pngHeader},
+		"fixtures/config.json": {content: []byte(`{"a":1}`)},
+	}
+	var reads []string
+	sender := &mockSender{
+		sendFunc: func(ctx context.Context, req *pluginv1.PluginRequest) (*pluginv1.PluginResponse, error) {
+			path := req.GetStorageRead().GetPath()
+			reads = append(reads, path)
+			e := entries[path]
+			md, _ := structpb.NewStruct(e.metadata)
+			return &pluginv1.PluginResponse{
+				Response: &pluginv1.PluginResponse_StorageRead{
+					StorageRead: &pluginv1.StorageReadResponse{Content: e.content, Metadata: md},
+				},
+			}, nil
+		},
+	}
+	namespaces := append([]ResourceNamespace{{Prefix: "fixtures/", Scheme: "fixtures"}}, defaultResourceNamespaces...)
+	tr := newReadyTransport(t, sender, nil, nil, WithResourceNamespaces(namespaces))
+
+	tests := []struct {
+		uri, path, mimeType string
+		text                string // expected text, if not a blob
+	}{
+		{"orchestra://docs/guide", "docs/guide.md", "text/markdown", "# Guide"},
+		{"orchestra://docs/diagram.png", "docs/diagram.png", "image/png", ""},
+		{"orchestra://docs/broken", "docs/broken.md", "text/markdown", ""},
+		{"orchestra://docs/report.pdf", "docs/report.pdf", "application/x-report", ""},
+		{"orchestra://fixtures/blob", "fixtures/blob", "image/png", ""},
+		{"orchestra://fixtures/config.json", "fixtures/config.json", "application/json", `{"a":1}`},
+	}
+	for _, tt := range tests {
+		reads = nil
+		resp := roundTrip(t, tr.dispatch(context.Background(), &protocol.JSONRPCRequest{
+			JSONRPC: "2.0", ID: 1, Method: "resources/read", Params: []byte(`{"uri":"` + tt.uri + `"}`),
+		}))
+		if resp.Error != nil {
+			t.Errorf("%s: unexpected error: %+v", tt.uri, resp.Error)
+			continue
+		}
+		if len(reads) != 1 || reads[0] != tt.path {
+			t.Errorf("%s: read %v, want %s", tt.uri, reads, tt.path)
+		}
+		c := resp.Result.(map[string]any)["contents"].([]any)[0].(map[string]any)
+		if c["mimeType"] != tt.mimeType {
+			t.Errorf("%s: mimeType %v, want %s", tt.uri, c["mimeType"], tt.mimeType)
+		}
+		if tt.text != "" {
+			if c["text"] != tt.text || c["blob"] != nil {
+				t.Errorf("%s: expected text %q, got %v", tt.uri, tt.text, c)
+			}
+			continue
+		}
+		if c["text"] != nil || c["blob"] != base64.StdEncoding.EncodeToString(entries[tt.path].content) {
+			t.Errorf("%s: expected base64 blob, got %v", tt.uri, c)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+)
+
+// resourceURIPrefix is the URI scheme under which storage entries are exposed.
+const resourceURIPrefix = "orchestra://"
+
+// defaultResourceMimeType is the mime type of namespaces that declare none.
+const defaultResourceMimeType = "text/plain"
+
+// ResourceNamespace exposes the storage entries under a prefix as MCP
+// resources named orchestra://<scheme>/<id>.
+type ResourceNamespace struct {
+	Prefix string `json:"prefix"` // storage prefix, e.g. "features/"
+	Scheme string `json:"scheme"` // URI segment, e.g. "features"
+	Name   string `json:"name"`   // human-readable name for templates
+
+	// Extension is the usual file extension of the namespace's entries, e.g.
+	// ".md". It is stripped from resource IDs and appended when reading.
+	// Entries with another known extension, such as "diagram.png", keep it in
+	// their ID. If empty, IDs are the full entry names.
+	Extension string `json:"extension,omitempty"`
+
+	// MimeType is the mime type of entries whose extension does not tell
+	// their type. It defaults to text/plain in listings; reads sniff the
+	// content instead.
+	MimeType string `json:"mimeType,omitempty"`
+}
+
+// defaultResourceNamespaces are the namespaces exposed unless configured
+// otherwise.
+var defaultResourceNamespaces = []ResourceNamespace{
+	{Prefix: "features/", Scheme: "features", Name: "Project Features", Extension: ".md", MimeType: "text/markdown"},
+	{Prefix: "notes/", Scheme: "notes", Name: "Project Notes", Extension: ".md", MimeType: "text/markdown"},
+	{Prefix: "docs/", Scheme: "docs", Name: "Project Documentation", Extension: ".md", MimeType: "text/markdown"},
+}
+
+// WithResourceNamespaces sets the storage namespaces exposed as resources by
+// resources/list, resources/read and resources/templates/list, replacing the
+// default features/, notes/ and docs/ namespaces. A nil list keeps the
+// defaults. The namespaces are assumed valid; LoadResourceNamespaces checks
+// namespaces read from a file.
+func WithResourceNamespaces(namespaces []ResourceNamespace) func(*StdioTransport) {
+	return func(t *StdioTransport) {
+		if namespaces != nil {
+			t.namespaces = namespaces
+		}
+	}
+}
+
+// LoadResourceNamespaces reads resource namespaces from a JSON file:
+//
+//	[{"prefix": "specs/", "scheme": "specs", "name": "Specifications", "extension": ".md", "mimeType": "text/markdown"},
+//	 {"prefix": "fixtures/", "scheme": "fixtures", "name": "Test Fixtures", "mimeType": "application/json"}]
+//
+// A missing trailing slash is added to prefixes, and name defaults to the
+// scheme.
+func LoadResourceNamespaces(file string) ([]ResourceNamespace, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("read resource namespaces: %w", err)
+	}
+	var namespaces []ResourceNamespace
+	if err := json.Unmarshal(data, &namespaces); err != nil {
+		return nil, fmt.Errorf("parse resource namespaces %s: %w", file, err)
+	}
+
+	schemes := make(map[string]bool, len(namespaces))
+	for i := range namespaces {
+		ns := &namespaces[i]
+		if ns.Prefix == "" || ns.Scheme == "" {
+			return nil, fmt.Errorf("resource namespaces %s: entry %d needs a prefix and a scheme", file, i)
+		}
+		if strings.Contains(ns.Scheme, "/") {
+			return nil, fmt.Errorf("resource namespaces %s: scheme %q must not contain '/'", file, ns.Scheme)
+		}
+		if schemes[ns.Scheme] {
+			return nil, fmt.Errorf("resource namespaces %s: duplicate scheme %q", file, ns.Scheme)
+		}
+		schemes[ns.Scheme] = true
+		if ns.Extension != "" && !strings.HasPrefix(ns.Extension, ".") {
+			return nil, fmt.Errorf("resource namespaces %s: extension %q must start with '.'", file, ns.Extension)
+		}
+		if !strings.HasSuffix(ns.Prefix, "/") {
+			ns.Prefix += "/"
+		}
+		if ns.Name == "" {
+			ns.Name = ns.Scheme
+		}
+	}
+	return namespaces, nil
+}
+
+// mimeType returns the namespace's mime type.
+func (ns ResourceNamespace) mimeType() string {
+	if ns.MimeType == "" {
+		return defaultResourceMimeType
+	}
+	return ns.MimeType
+}
+
+// uri returns the canonical resource URI of an ID in the namespace.
+func (ns ResourceNamespace) uri(id string) string {
+	return resourceURIPrefix + ns.Scheme + "/" + escapeResourceID(id)
+}
+
+// uriTemplate returns the URI template of the namespace's resources.
+func (ns ResourceNamespace) uriTemplate() string {
+	return resourceURIPrefix + ns.Scheme + "/{id}"
+}
+
+// resourceID maps a storage path in the namespace to its resource ID, such as
+// "api/auth" for "docs/api/auth.md". It reports false for paths outside the
+// namespace and for entries that could not be read back by ID.
+func (ns ResourceNamespace) resourceID(path string) (string, bool) {
+	id, ok := strings.CutPrefix(path, ns.Prefix)
+	if !ok {
+		return "", false
+	}
+	if ns.Extension != "" {
+		if stripped, ok := strings.CutSuffix(id, ns.Extension); ok {
+			id = stripped
+		} else if !hasKnownExtension(id) {
+			return "", false
+		}
+	}
+	return id, checkResourceID(id) == nil
+}
+
+// storagePath is the inverse of resourceID: it maps a resource ID to the
+// storage path of its entry.
+func (ns ResourceNamespace) storagePath(id string) string {
+	if ns.Extension == "" || hasKnownExtension(id) {
+		return ns.Prefix + id
+	}
+	return ns.Prefix + id + ns.Extension
+}
+
+// listMimeType returns the mime type listed for the entry at a storage path.
+func (ns ResourceNamespace) listMimeType(storagePath string) string {
+	if t := mimeTypeForExtension(path.Ext(storagePath)); t != "" {
+		return t
+	}
+	return ns.mimeType()
+}
+
+// resolvedResource is a resource URI resolved to its namespace and storage
+// entry.
+type resolvedResource struct {
+	uri         string // canonical URI
+	storagePath string
+	ns          ResourceNamespace
+}
+
+// resolveResourceURI maps a resource URI such as "orchestra://docs/api/auth"
+// to its storage path ("docs/api/auth.md") and namespace. IDs that would
+// escape the namespace are rejected, so the storage path always lies under the
+// namespace's prefix.
+func (t *StdioTransport) resolveResourceURI(uri string) (resolvedResource, error) {
+	rest, ok := strings.CutPrefix(uri, resourceURIPrefix)
+	if !ok {
+		return resolvedResource{}, fmt.Errorf("unsupported URI scheme: %q (expected %s)", uri, resourceURIPrefix)
+	}
+
+	scheme, rawID, ok := strings.Cut(rest, "/")
+	if !ok || rawID == "" {
+		return resolvedResource{}, fmt.Errorf("invalid resource URI: %q", uri)
+	}
+
+	for _, ns := range t.namespaces {
+		if ns.Scheme != scheme {
+			continue
+		}
+		id, err := decodeResourceID(rawID)
+		if err != nil {
+			return resolvedResource{}, err
+		}
+		return resolvedResource{uri: ns.uri(id), storagePath: ns.storagePath(id), ns: ns}, nil
+	}
+	return resolvedResource{}, fmt.Errorf("unknown resource type: %q", scheme)
+}
+
+// namespaceForPath returns the namespace a storage path belongs to. When
+// namespaces overlap, the one with the longest prefix wins.
+func (t *StdioTransport) namespaceForPath(path string) (ResourceNamespace, bool) {
+	var match ResourceNamespace
+	for _, ns := range t.namespaces {
+		if strings.HasPrefix(path, ns.Prefix) && len(ns.Prefix) > len(match.Prefix) {
+			match = ns
+		}
+	}
+	return match, match.Prefix != ""
+}
+
+// resourceURIForPath is the inverse of resolveResourceURI: it maps a storage
+// path such as "features/FEAT-ABC.md" to its resource URI and display name.
+// It reports false for paths outside the exposed namespaces.
+func (t *StdioTransport) resourceURIForPath(path string) (uri, name string, ok bool) {
+	ns, ok := t.namespaceForPath(path)
+	if !ok {
+		return "", "", false
+	}
+	if name, ok = ns.resourceID(path); !ok {
+		return "", "", false
+	}
+	return ns.uri(name), name, true
+}
@@ -0,0 +1,163 @@
+package internal
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	pluginv1 "github.com/orchestra-mcp/gen-go/orchestra/plugin/v1"
+	"github.com/orchestra-mcp/sdk-go/protocol"
+)
+
+var testNamespaces = []ResourceNamespace{
+	{Prefix: "specs/", Scheme: "specs", Name: "Specifications", Extension: ".md", MimeType: "text/markdown"},
+	{Prefix: "specs/adr/", Scheme: "adr", Name: "Decision Records", Extension: ".md", MimeType: "text/markdown"},
+	{Prefix: "fixtures/", Scheme: "fixtures", Name: "Test Fixtures", MimeType: "application/json"},
+}
+
+// storageSender serves StorageList and StorageRead requests from files, keyed
+// by path, and records the paths read.
+func storageSender(files map[string]string, reads *[]string) *mockSender {
+	var mu sync.Mutex
+	return &mockSender{
+		sendFunc: func(ctx context.Context, req *pluginv1.PluginRequest) (*pluginv1.PluginResponse, error) {
+			if sr := req.GetStorageRead(); sr != nil {
+				mu.Lock()
+				*reads = append(*reads, sr.GetPath())
+				mu.Unlock()
+				return &pluginv1.PluginResponse{
+					Response: &pluginv1.PluginResponse_StorageRead{
+						StorageRead: &pluginv1.StorageReadResponse{Content: []byte(files[sr.GetPath()])},
+					},
+				}, nil
+			}
+			list := &pluginv1.StorageListResponse{}
+			for path := range files {
+				if strings.HasPrefix(path, req.GetStorageList().GetPrefix()) {
+					list.Entries = append(list.Entries, &pluginv1.StorageEntry{Path: path})
+				}
+			}
+			return &pluginv1.PluginResponse{Response: &pluginv1.PluginResponse_StorageList{StorageList: list}}, nil
+		},
+	}
+}
+
+func TestResourceNamespaces(t *testing.T) {
+	files := map[string]string{
+		"specs/auth.md":      "# Auth",
+		"specs/notes.txt":    "not a spec",
+		"specs/README":       "unreadable by ID",
+		"specs/adr/0001.md":  "# ADR 1",
+		"fixtures/user.json": `{"id":1}`,
+		"features/FEAT-1.md": "# hidden",
+	}
+	var reads []string
+	tr := newReadyTransport(t, storageSender(files, &reads), nil, nil, WithResourceNamespaces(testNamespaces))
+	ctx := context.Background()
+
+	resp := roundTrip(t, tr.dispatch(ctx, &protocol.JSONRPCRequest{JSONRPC: "2.0", ID: 1, Method: "resources/list"}))
+	got := map[string]string{}
+	for _, r := range resp.Result.(map[string]any)["resources"].([]any) {
+		r := r.(map[string]any)
+		got[r["uri"].(string)] = r["mimeType"].(string)
+	}
+	want := map[string]string{
+		"orchestra://specs/auth":         "text/markdown",
+		"orchestra://specs/notes.txt":    "text/plain",
+		"orchestra://adr/0001":           "text/markdown",
+		"orchestra://fixtures/user.json": "application/json",
+	}
+	if len(got) != len(want) {
+		t.Errorf("resources: got %v, want %v", got, want)
+	}
+	for uri, mime := range want {
+		if got[uri] != mime {
+			t.Errorf("%s: got mime type %q, want %q", uri, got[uri], mime)
+		}
+	}
+	reads = nil // listing read each resource for its description
+
+	for uri, path := range map[string]string{
+		"orchestra://adr/0001":           "specs/adr/0001.md",
+		"orchestra://fixtures/user.json": "fixtures/user.json",
+	} {
+		resp := roundTrip(t, tr.dispatch(ctx, &protocol.JSONRPCRequest{
+			JSONRPC: "2.0", ID: 2, Method: "resources/read", Params: []byte(`{"uri":"` + uri + `"}`),
+		}))
+		if resp.Error != nil {
+			t.Fatalf("read %s: %+v", uri, resp.Error)
+		}
+		content := resp.Result.(map[string]any)["contents"].([]any)[0].(map[string]any)
+		if content["text"] != files[path] {
+			t.Errorf("read %s: got %v", uri, content)
+		}
+	}
+	if len(reads) != 2 || !slices.Contains(reads, "specs/adr/0001.md") {
+		t.Errorf("unexpected storage reads: %v", reads)
+	}
+
+	resp = roundTrip(t, tr.dispatch(ctx, &protocol.JSONRPCRequest{
+		JSONRPC: "2.0", ID: 3, Method: "resources/read", Params: []byte(`{"uri":"orchestra://features/FEAT-1"}`),
+	}))
+	if resp.Error == nil || resp.Error.Code != protocol.InvalidParams {
+		t.Errorf("expected InvalidParams for a default namespace, got %+v", resp)
+	}
+
+	resp = roundTrip(t, tr.dispatch(ctx, &protocol.JSONRPCRequest{JSONRPC: "2.0", ID: 4, Method: "resources/templates/list"}))
+	templates := resp.Result.(map[string]any)["resourceTemplates"].([]any)
+	if len(templates) != len(testNamespaces) || templates[2].(map[string]any)["uriTemplate"] != "orchestra://fixtures/{id}" {
+		t.Errorf("unexpected templates: %v", templates)
+	}
+}
+
+func TestResourceURIForPathPrefersLongestPrefix(t *testing.T) {
+	tr := NewStdioTransport(&mockSender{}, nil, nil, WithResourceNamespaces(testNamespaces))
+	tests := []struct {
+		path, uri string
+		ok        bool
+	}{
+		{"specs/auth.md", "orchestra://specs/auth", true},
+		{"specs/adr/0002.md", "orchestra://adr/0002", true},
+		{"specs/readme.txt", "orchestra://specs/readme.txt", true},
+		{"specs/README", "", false},
+		{"fixtures/a.json", "orchestra://fixtures/a.json", true},
+		{"notes/n.md", "", false},
+	}
+	for _, tt := range tests {
+		uri, _, ok := tr.resourceURIForPath(tt.path)
+		if uri != tt.uri || ok != tt.ok {
+			t.Errorf("resourceURIForPath(%q) = %q, %v; want %q, %v", tt.path, uri, ok, tt.uri, tt.ok)
+		}
+	}
+}
+
+func TestLoadResourceNamespaces(t *testing.T) {
+	dir := t.TempDir()
+	good := filepath.Join(dir, "namespaces.json")
+	os.WriteFile(good, []byte(`[{"prefix":"specs","scheme":"specs","extension":".md"},{"prefix":"fixtures/","scheme":"fixtures","mimeType":"application/json"}]`), 0o600)
+
+	namespaces, err := LoadResourceNamespaces(good)
+	if err != nil {
+		t.Fatalf("LoadResourceNamespaces: %v", err)
+	}
+	if len(namespaces) != 2 || namespaces[0].Prefix != "specs/" || namespaces[0].Name != "specs" || namespaces[0].mimeType() != defaultResourceMimeType {
+		t.Errorf("unexpected namespaces: %+v", namespaces)
+	}
+
+	for name, content := range map[string]string{
+		"missing_scheme.json": `[{"prefix":"specs/"}]`,
+		"duplicate.json":      `[{"prefix":"a/","scheme":"a"},{"prefix":"b/","scheme":"a"}]`,
+		"bad_extension.json":  `[{"prefix":"a/","scheme":"a","extension":"md"}]`,
+		"nested_scheme.json":  `[{"prefix":"a/","scheme":"a/b"}]`,
+	} {
+		file := filepath.Join(dir, name)
+		os.WriteFile(file, []byte(content), 0o600)
+		if _, err := LoadResourceNamespaces(file); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
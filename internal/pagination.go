@@ -0,0 +1,122 @@
+package internal
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+// errInvalidCursor is returned for cursors that were not issued by this
+// transport, were issued for a different method, or cannot be decoded.
+var errInvalidCursor = errors.New("invalid cursor")
+
+// errStaleCursor is returned when the item a cursor points after no longer
+// exists in the list, so the next page cannot be located reliably.
+var errStaleCursor = errors.New("stale cursor: list changed, restart pagination")
+
+// listParams is the expected shape of params for the paginated list methods.
+type listParams struct {
+	Cursor string `json:"cursor,omitempty"`
+}
+
+// pageCursor is the decoded form of an opaque pagination cursor. It records the
+// offset of the next page together with the key of the last item returned so
+// that pagination stays stable when items are added or removed before it.
+type pageCursor struct {
+	Method string `json:"m"`
+	Offset int    `json:"o"`
+	Last   string `json:"l"`
+}
+
+// newCursorKey returns a random key for signing pagination cursors. Cursors are
+// only valid for the transport (session) that issued them.
+func newCursorKey() []byte {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil
+	}
+	return key
+}
+
+// encodeCursor serializes and signs a cursor as "<payload>.<mac>", both
+// base64url-encoded.
+func (t *StdioTransport) encodeCursor(c pageCursor) string {
+	payload, err := json.Marshal(c)
+	if err != nil {
+		return ""
+	}
+	return base64.RawURLEncoding.EncodeToString(payload) + "." +
+		base64.RawURLEncoding.EncodeToString(t.cursorMAC(payload))
+}
+
+// decodeCursor verifies and decodes a cursor issued for method. An empty
+// cursor decodes to the first page.
+func (t *StdioTransport) decodeCursor(method, cursor string) (pageCursor, error) {
+	if cursor == "" {
+		return pageCursor{Method: method}, nil
+	}
+
+	encPayload, encMAC, ok := strings.Cut(cursor, ".")
+	if !ok {
+		return pageCursor{}, errInvalidCursor
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encPayload)
+	if err != nil {
+		return pageCursor{}, errInvalidCursor
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(encMAC)
+	if err != nil || !hmac.Equal(mac, t.cursorMAC(payload)) {
+		return pageCursor{}, errInvalidCursor
+	}
+
+	var c pageCursor
+	if err := json.Unmarshal(payload, &c); err != nil || c.Method != method || c.Offset <= 0 {
+		return pageCursor{}, errInvalidCursor
+	}
+	return c, nil
+}
+
+// cursorMAC signs a cursor payload with the transport's cursor key.
+func (t *StdioTransport) cursorMAC(payload []byte) []byte {
+	h := hmac.New(sha256.New, t.cursorKey)
+	h.Write(payload)
+	return h.Sum(nil)
+}
+
+// page selects the slice of a list to return for cursor c. keys holds the
+// stable key of every item (tool name, prompt name or resource URI) in list
+// order. It returns the half-open range [start, end) and the cursor for the
+// following page, which is empty on the last page. With pagination disabled
+// (page size 0) the whole list is returned.
+func (t *StdioTransport) page(c pageCursor, keys []string) (start, end int, next string, err error) {
+	if c.Offset > 0 {
+		switch {
+		case c.Offset <= len(keys) && keys[c.Offset-1] == c.Last:
+			start = c.Offset
+		default:
+			// Items before the cursor were added or removed; resume after
+			// the last item the client saw, wherever it is now.
+			start = -1
+			for i, k := range keys {
+				if k == c.Last {
+					start = i + 1
+					break
+				}
+			}
+			if start < 0 {
+				return 0, 0, "", errStaleCursor
+			}
+		}
+	}
+
+	end = len(keys)
+	if t.pageSize > 0 && start+t.pageSize < end {
+		end = start + t.pageSize
+		next = t.encodeCursor(pageCursor{Method: c.Method, Offset: end, Last: keys[end-1]})
+	}
+	return start, end, next, nil
+}
@@ -0,0 +1,69 @@
+package internal
+
+import (
+	"log/slog"
+
+	pluginv1 "github.com/orchestra-mcp/gen-go/orchestra/plugin/v1"
+)
+
+// progressEventTopic is the orchestrator event topic carrying progress updates
+// for running tool calls. The payload identifies the call by the orchestrator
+// request ID of its ToolRequest:
+//
+//	{"request_id": "stdio-tc-5f0c...-7", "progress": 3, "total": 10, "message": "..."}
+const progressEventTopic = "tool.progress"
+
+// requestMeta is the MCP "_meta" object that may accompany request params.
+type requestMeta struct {
+	ProgressToken any   `json:"progressToken,omitempty"`
+	TimeoutMs     int64 `json:"timeoutMs,omitempty"` // client timeout hint; see RequestTimeouts
+}
+
+// progressParams is the JSON shape of a notifications/progress message.
+type progressParams struct {
+	ProgressToken any     `json:"progressToken"`
+	Progress      float64 `json:"progress"`
+	Total         float64 `json:"total,omitempty"`
+	Message       string  `json:"message,omitempty"`
+}
+
+// trackProgress associates a client progress token with the orchestrator
+// request ID of an outgoing ToolRequest.
+func (t *StdioTransport) trackProgress(requestID string, token any) {
+	t.progressMu.Lock()
+	defer t.progressMu.Unlock()
+	if t.progressTokens == nil {
+		t.progressTokens = make(map[string]any)
+	}
+	t.progressTokens[requestID] = token
+}
+
+// untrackProgress drops the progress token mapping once a tool call completes.
+func (t *StdioTransport) untrackProgress(requestID string) {
+	t.progressMu.Lock()
+	defer t.progressMu.Unlock()
+	delete(t.progressTokens, requestID)
+}
+
+// handleProgressEvent converts a tool progress event into a
+// notifications/progress message for the client. Events for calls that did not
+// ask for progress, or that have already completed, are dropped.
+func (t *StdioTransport) handleProgressEvent(ev *pluginv1.EventDelivery) error {
+	fields := ev.GetPayload().GetFields()
+	requestID := fields["request_id"].GetStringValue()
+
+	t.progressMu.Lock()
+	token, ok := t.progressTokens[requestID]
+	t.progressMu.Unlock()
+	if !ok {
+		slog.Debug("dropping progress for untracked request", "request_id", requestID)
+		return nil
+	}
+
+	return t.writeNotification("notifications/progress", progressParams{
+		ProgressToken: token,
+		Progress:      fields["progress"].GetNumberValue(),
+		Total:         fields["total"].GetNumberValue(),
+		Message:       fields["message"].GetStringValue(),
+	})
+}
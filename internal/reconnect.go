@@ -0,0 +1,351 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"sync"
+	"time"
+
+	pluginv1 "github.com/orchestra-mcp/gen-go/orchestra/plugin/v1"
+	"github.com/quic-go/quic-go"
+)
+
+// Default reconnection settings used by NewReconnectingSender.
+const (
+	defaultInitialBackoff = 250 * time.Millisecond
+	defaultMaxBackoff     = 30 * time.Second
+	defaultMaxQueued      = 64
+	defaultGiveUpAfter    = 5 * time.Minute
+
+	// minBackoff is the shortest delay between reconnection attempts, so
+	// that a zero backoff does not dial in a tight loop.
+	minBackoff = 10 * time.Millisecond
+)
+
+var (
+	// errQueueFull is returned when too many requests are already waiting for
+	// the orchestrator connection to come back.
+	errQueueFull = errors.New("orchestrator reconnecting: request queue full")
+
+	// errSenderClosed is returned by Send after Close.
+	errSenderClosed = errors.New("orchestrator sender closed")
+)
+
+// OrchestratorConn is a closable connection to the orchestrator. In production
+// this is a plugin.OrchestratorClient.
+type OrchestratorConn interface {
+	Sender
+	Close() error
+}
+
+// Dialer opens a new connection to the orchestrator.
+type Dialer func(ctx context.Context) (OrchestratorConn, error)
+
+// LinkState describes a change in the orchestrator connection.
+type LinkState int
+
+const (
+	// LinkDown means the connection failed and reconnection has started.
+	LinkDown LinkState = iota
+	// LinkUp means the connection was re-established.
+	LinkUp
+	// LinkLost means reconnection gave up after the give-up deadline. The
+	// next Send starts a new reconnection attempt.
+	LinkLost
+)
+
+// LinkNotifier is called when the orchestrator connection changes state. err
+// is the failure that caused a LinkDown or LinkLost and nil for LinkUp. The
+// calls for one outage are made in order from a single goroutine.
+type LinkNotifier func(state LinkState, err error)
+
+// ReconnectingSender is a Sender that re-dials the orchestrator when the
+// connection drops. Reconnection uses exponential backoff with jitter. While
+// it is in progress, up to a bounded number of requests wait for the link to
+// come back; requests beyond that fail immediately. If the link is not back
+// before the give-up deadline, the waiting requests fail.
+//
+// Only connection-level failures start a reconnection; an error confined to
+// one request's stream leaves the connection, and the other calls on it, in
+// place. A request whose Send fails is not retried, since the orchestrator
+// may already have executed it.
+type ReconnectingSender struct {
+	dial           Dialer
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+	maxQueued      int
+	giveUpAfter    time.Duration // 0 retries forever
+	notify         LinkNotifier
+
+	ctx    context.Context // cancelled by Close to stop reconnecting
+	cancel context.CancelFunc
+
+	mu      sync.Mutex
+	conn    OrchestratorConn // nil while disconnected
+	ready   chan struct{}    // closed when the current outage ends; nil when connected
+	lastErr error            // why the last reconnection gave up
+	waiting int              // requests waiting on ready
+	closed  bool
+}
+
+// NewReconnectingSender creates a ReconnectingSender that obtains connections
+// from dial. Call Connect to establish the initial connection.
+func NewReconnectingSender(dial Dialer, opts ...func(*ReconnectingSender)) *ReconnectingSender {
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &ReconnectingSender{
+		dial:           dial,
+		initialBackoff: defaultInitialBackoff,
+		maxBackoff:     defaultMaxBackoff,
+		maxQueued:      defaultMaxQueued,
+		giveUpAfter:    defaultGiveUpAfter,
+		ctx:            ctx,
+		cancel:         cancel,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	r.initialBackoff = max(r.initialBackoff, minBackoff)
+	r.maxBackoff = max(r.maxBackoff, r.initialBackoff)
+	return r
+}
+
+// WithBackoff sets the initial and maximum delay between reconnection
+// attempts. The delay doubles after every failed attempt, with jitter. Delays
+// below 10ms are raised to 10ms.
+func WithBackoff(initial, max time.Duration) func(*ReconnectingSender) {
+	return func(r *ReconnectingSender) {
+		r.initialBackoff = initial
+		r.maxBackoff = max
+	}
+}
+
+// WithMaxQueued sets how many requests may wait for a reconnection at once.
+func WithMaxQueued(n int) func(*ReconnectingSender) {
+	return func(r *ReconnectingSender) {
+		r.maxQueued = n
+	}
+}
+
+// WithGiveUpAfter sets how long reconnection is attempted before waiting
+// requests fail. A duration of 0 retries forever.
+func WithGiveUpAfter(d time.Duration) func(*ReconnectingSender) {
+	return func(r *ReconnectingSender) {
+		r.giveUpAfter = d
+	}
+}
+
+// WithLinkNotifier sets a callback invoked when the connection goes down,
+// comes back, or reconnection gives up.
+func WithLinkNotifier(fn LinkNotifier) func(*ReconnectingSender) {
+	return func(r *ReconnectingSender) {
+		r.notify = fn
+	}
+}
+
+// Connect dials the initial connection. Unlike later reconnections, a failure
+// here is returned to the caller.
+func (r *ReconnectingSender) Connect(ctx context.Context) error {
+	conn, err := r.dial(ctx)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	r.conn = conn
+	r.mu.Unlock()
+	return nil
+}
+
+// Send implements Sender. If the connection is down, Send waits for it to be
+// re-established, subject to the queue bound, the give-up deadline and ctx.
+func (r *ReconnectingSender) Send(ctx context.Context, req *pluginv1.PluginRequest) (*pluginv1.PluginResponse, error) {
+	conn, err := r.connection(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := conn.Send(ctx, req)
+	if err != nil && ctx.Err() == nil && isConnectionError(err) {
+		r.connectionFailed(conn, err)
+	}
+	return resp, err
+}
+
+// isConnectionError reports whether a Send error means the QUIC connection
+// itself failed, as opposed to the request's stream: the connection was
+// closed by either side, timed out, or was reset.
+func isConnectionError(err error) bool {
+	var (
+		transportErr *quic.TransportError
+		appErr       *quic.ApplicationError
+		idleErr      *quic.IdleTimeoutError
+		handshakeErr *quic.HandshakeTimeoutError
+		resetErr     *quic.StatelessResetError
+		versionErr   *quic.VersionNegotiationError
+	)
+	return errors.As(err, &transportErr) || errors.As(err, &appErr) ||
+		errors.As(err, &idleErr) || errors.As(err, &handshakeErr) ||
+		errors.As(err, &resetErr) || errors.As(err, &versionErr) ||
+		errors.Is(err, net.ErrClosed)
+}
+
+// Close stops any reconnection in progress and closes the current connection.
+func (r *ReconnectingSender) Close() error {
+	r.cancel()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.closed = true
+	if r.conn == nil {
+		return nil
+	}
+	err := r.conn.Close()
+	r.conn = nil
+	return err
+}
+
+// connection returns the live connection, waiting for a reconnection if one
+// is in progress.
+func (r *ReconnectingSender) connection(ctx context.Context) (OrchestratorConn, error) {
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return nil, errSenderClosed
+	}
+	if r.conn != nil {
+		conn := r.conn
+		r.mu.Unlock()
+		return conn, nil
+	}
+	if r.ready == nil {
+		// A previous reconnection gave up; try again for this request.
+		r.startReconnect(r.lastErr, false)
+	}
+	if r.waiting >= r.maxQueued {
+		r.mu.Unlock()
+		return nil, errQueueFull
+	}
+	r.waiting++
+	ready := r.ready
+	r.mu.Unlock()
+
+	defer func() {
+		r.mu.Lock()
+		r.waiting--
+		r.mu.Unlock()
+	}()
+
+	select {
+	case <-ready:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.conn == nil {
+		if r.closed {
+			return nil, errSenderClosed
+		}
+		return nil, fmt.Errorf("orchestrator unreachable: %w", r.lastErr)
+	}
+	return r.conn, nil
+}
+
+// connectionFailed drops a failed connection and starts reconnecting, unless
+// another request already did so.
+func (r *ReconnectingSender) connectionFailed(conn OrchestratorConn, cause error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.conn != conn || r.closed {
+		return
+	}
+	r.conn = nil
+	conn.Close()
+	r.startReconnect(cause, true)
+}
+
+// startReconnect begins a reconnection cycle, reporting LinkDown first if
+// down is set. The caller must hold r.mu.
+func (r *ReconnectingSender) startReconnect(cause error, down bool) {
+	r.ready = make(chan struct{})
+	go r.reconnect(r.ready, cause, down)
+}
+
+// reconnect dials with exponential backoff until it succeeds, the give-up
+// deadline passes, or the sender is closed. It closes ready when done. Link
+// changes are reported from this goroutine, so LinkDown always precedes the
+// LinkUp or LinkLost that ends the outage.
+func (r *ReconnectingSender) reconnect(ready chan struct{}, cause error, down bool) {
+	if down && r.notify != nil {
+		r.notify(LinkDown, cause)
+	}
+
+	var deadline time.Time
+	if r.giveUpAfter > 0 {
+		deadline = time.Now().Add(r.giveUpAfter)
+	}
+
+	lastErr := cause
+	for attempt := 0; ; attempt++ {
+		conn, err := r.dial(r.ctx)
+		if err == nil {
+			r.mu.Lock()
+			if r.closed {
+				r.mu.Unlock()
+				conn.Close()
+				close(ready)
+				return
+			}
+			r.conn = conn
+			r.ready = nil
+			r.mu.Unlock()
+			close(ready)
+			if r.notify != nil {
+				r.notify(LinkUp, nil)
+			}
+			return
+		}
+		lastErr = err
+
+		delay := r.backoff(attempt)
+		if !deadline.IsZero() && time.Now().Add(delay).After(deadline) {
+			break
+		}
+		select {
+		case <-time.After(delay):
+		case <-r.ctx.Done():
+			close(ready)
+			return
+		}
+	}
+
+	r.mu.Lock()
+	r.lastErr = fmt.Errorf("gave up reconnecting after %s: %w", r.giveUpAfter, lastErr)
+	r.ready = nil
+	r.mu.Unlock()
+	close(ready)
+	if r.notify != nil {
+		r.notify(LinkLost, lastErr)
+	}
+}
+
+// backoff returns the delay before reconnection attempt n+1: the initial
+// backoff doubled n times, capped at the maximum, with "equal jitter" so that
+// the delay falls between half and all of that value.
+func (r *ReconnectingSender) backoff(n int) time.Duration {
+	d := r.initialBackoff
+	for i := 0; i < n && d < r.maxBackoff; i++ {
+		d *= 2
+	}
+	if d > r.maxBackoff {
+		d = r.maxBackoff
+	}
+	half := d / 2
+	if half <= 0 {
+		return d
+	}
+	return half + rand.N(half+1)
+}
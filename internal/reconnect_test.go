@@ -0,0 +1,307 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	pluginv1 "github.com/orchestra-mcp/gen-go/orchestra/plugin/v1"
+	"github.com/quic-go/quic-go"
+)
+
+// fakeConn is an OrchestratorConn whose Send fails once broken, and fails
+// requests named "stream-error" as a reset stream would.
+type fakeConn struct {
+	mu     sync.Mutex
+	id     int
+	broken bool
+	closed bool
+}
+
+func (c *fakeConn) Send(ctx context.Context, req *pluginv1.PluginRequest) (*pluginv1.PluginResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.broken {
+		return nil, fmt.Errorf("open stream: %w", &quic.IdleTimeoutError{})
+	}
+	if req.RequestId == "stream-error" {
+		return nil, fmt.Errorf("read response: %w", &quic.StreamError{ErrorCode: 1, Remote: true})
+	}
+	return &pluginv1.PluginResponse{RequestId: req.RequestId}, nil
+}
+
+func (c *fakeConn) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	return nil
+}
+
+func (c *fakeConn) breakLink() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.broken = true
+}
+
+// fakeDialer hands out fakeConns. Each dial first takes a result from results
+// when one is queued, so tests can script failures and block dials.
+type fakeDialer struct {
+	mu      sync.Mutex
+	conns   []*fakeConn
+	results chan error
+}
+
+func newFakeDialer() *fakeDialer {
+	return &fakeDialer{results: make(chan error, 16)}
+}
+
+func (d *fakeDialer) dial(ctx context.Context) (OrchestratorConn, error) {
+	select {
+	case err := <-d.results:
+		if err != nil {
+			return nil, err
+		}
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	c := &fakeConn{id: len(d.conns)}
+	d.conns = append(d.conns, c)
+	return c, nil
+}
+
+func (d *fakeDialer) conn(i int) *fakeConn {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.conns[i]
+}
+
+// linkRecorder collects LinkNotifier calls.
+type linkRecorder struct {
+	states chan LinkState
+}
+
+func newLinkRecorder() *linkRecorder {
+	return &linkRecorder{states: make(chan LinkState, 16)}
+}
+
+func (l *linkRecorder) notify(state LinkState, err error) {
+	l.states <- state
+}
+
+func (l *linkRecorder) expect(t *testing.T, want LinkState) {
+	t.Helper()
+	select {
+	case got := <-l.states:
+		if got != want {
+			t.Fatalf("link state: got %d, want %d", got, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out waiting for link state %d", want)
+	}
+}
+
+func TestReconnectingSenderReconnects(t *testing.T) {
+	d := newFakeDialer()
+	links := newLinkRecorder()
+	r := NewReconnectingSender(d.dial,
+		WithBackoff(time.Millisecond, 5*time.Millisecond),
+		WithLinkNotifier(links.notify),
+	)
+	defer r.Close()
+
+	ctx := context.Background()
+	if err := r.Connect(ctx); err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	if _, err := r.Send(ctx, &pluginv1.PluginRequest{RequestId: "a"}); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+
+	// The failing request is not retried; the next one waits for the link.
+	d.results <- errors.New("refused")
+	d.results <- errors.New("refused")
+	d.conn(0).breakLink()
+	if _, err := r.Send(ctx, &pluginv1.PluginRequest{RequestId: "b"}); err == nil {
+		t.Fatal("expected send on broken connection to fail")
+	}
+	resp, err := r.Send(ctx, &pluginv1.PluginRequest{RequestId: "c"})
+	if err != nil {
+		t.Fatalf("send after reconnect: %v", err)
+	}
+	if resp.RequestId != "c" {
+		t.Errorf("response request ID: got %q, want %q", resp.RequestId, "c")
+	}
+
+	links.expect(t, LinkDown)
+	links.expect(t, LinkUp)
+	if !d.conn(0).closed {
+		t.Error("expected broken connection to be closed")
+	}
+}
+
+func TestReconnectingSenderQueueFull(t *testing.T) {
+	d := newFakeDialer()
+	r := NewReconnectingSender(d.dial,
+		WithBackoff(time.Millisecond, time.Millisecond),
+		WithMaxQueued(1),
+		WithGiveUpAfter(0),
+	)
+	defer r.Close()
+
+	ctx := context.Background()
+	if err := r.Connect(ctx); err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	// Keep reconnection failing until the test queues a success.
+	for range cap(d.results) {
+		d.results <- errors.New("refused")
+	}
+	d.conn(0).breakLink()
+	r.Send(ctx, &pluginv1.PluginRequest{RequestId: "broken"})
+
+	waitCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	queued := make(chan error, 1)
+	go func() {
+		_, err := r.Send(waitCtx, &pluginv1.PluginRequest{RequestId: "queued"})
+		queued <- err
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		r.mu.Lock()
+		waiting := r.waiting
+		r.mu.Unlock()
+		if waiting == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for queued request")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if _, err := r.Send(ctx, &pluginv1.PluginRequest{RequestId: "overflow"}); !errors.Is(err, errQueueFull) {
+		t.Errorf("expected errQueueFull, got %v", err)
+	}
+
+	cancel()
+	if err := <-queued; !errors.Is(err, context.Canceled) {
+		t.Errorf("queued request: expected context.Canceled, got %v", err)
+	}
+}
+
+func TestZeroBackoffIsClamped(t *testing.T) {
+	r := NewReconnectingSender(nil, WithBackoff(0, 0))
+	for n := range 3 {
+		if got := r.backoff(n); got < minBackoff/2 || got > minBackoff {
+			t.Errorf("backoff(%d) = %v, want in [%v, %v]", n, got, minBackoff/2, minBackoff)
+		}
+	}
+}
+
+func TestReconnectingSenderGivesUp(t *testing.T) {
+	d := newFakeDialer()
+	links := newLinkRecorder()
+	r := NewReconnectingSender(d.dial,
+		WithBackoff(time.Millisecond, 2*time.Millisecond),
+		WithGiveUpAfter(20*time.Millisecond),
+		WithLinkNotifier(links.notify),
+	)
+	defer r.Close()
+
+	ctx := context.Background()
+	if err := r.Connect(ctx); err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	for range cap(d.results) {
+		d.results <- errors.New("refused")
+	}
+	d.conn(0).breakLink()
+	r.Send(ctx, &pluginv1.PluginRequest{RequestId: "broken"})
+
+	_, err := r.Send(ctx, &pluginv1.PluginRequest{RequestId: "queued"})
+	if err == nil || !strings.Contains(err.Error(), "gave up reconnecting") {
+		t.Fatalf("expected give-up error, got %v", err)
+	}
+	links.expect(t, LinkDown)
+	links.expect(t, LinkLost)
+
+	// Once the orchestrator is reachable again, a new request reconnects.
+	for len(d.results) > 0 {
+		<-d.results
+	}
+	if _, err := r.Send(ctx, &pluginv1.PluginRequest{RequestId: "later"}); err != nil {
+		t.Fatalf("send after give-up: %v", err)
+	}
+	links.expect(t, LinkUp)
+}
+
+func TestReconnectBackoff(t *testing.T) {
+	r := NewReconnectingSender(nil, WithBackoff(100*time.Millisecond, time.Second))
+	for n, want := range []time.Duration{100, 200, 400, 800, 1000, 1000} {
+		want *= time.Millisecond
+		for range 20 {
+			got := r.backoff(n)
+			if got < want/2 || got > want {
+				t.Fatalf("backoff(%d) = %v, want in [%v, %v]", n, got, want/2, want)
+			}
+		}
+	}
+}
+
+func TestReconnectingSenderKeepsConnectionOnStreamErrors(t *testing.T) {
+	d := newFakeDialer()
+	links := newLinkRecorder()
+	r := NewReconnectingSender(d.dial, WithLinkNotifier(links.notify))
+	defer r.Close()
+
+	ctx := context.Background()
+	if err := r.Connect(ctx); err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	if _, err := r.Send(ctx, &pluginv1.PluginRequest{RequestId: "stream-error"}); err == nil {
+		t.Fatal("expected the stream error to be returned")
+	}
+	if _, err := r.Send(ctx, &pluginv1.PluginRequest{RequestId: "next"}); err != nil {
+		t.Fatalf("send after a stream error: %v", err)
+	}
+	if d.conn(0).closed || len(d.conns) != 1 {
+		t.Error("expected the connection to be kept after a stream error")
+	}
+	select {
+	case state := <-links.states:
+		t.Errorf("unexpected link state %d", state)
+	default:
+	}
+}
+
+func TestIsConnectionError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{fmt.Errorf("open stream: %w", &quic.IdleTimeoutError{}), true},
+		{fmt.Errorf("open stream: %w", &quic.ApplicationError{Remote: true, ErrorMessage: "shutting down"}), true},
+		{&quic.TransportError{ErrorCode: quic.InternalError}, true},
+		{&quic.StatelessResetError{}, true},
+		{fmt.Errorf("write request: %w", net.ErrClosed), true},
+		{fmt.Errorf("read response: %w", &quic.StreamError{ErrorCode: 1, Remote: true}), false},
+		{fmt.Errorf("read response: %w", io.ErrUnexpectedEOF), false},
+		{errors.New("message too large"), false},
+	}
+	for _, tt := range tests {
+		if got := isConnectionError(tt.err); got != tt.want {
+			t.Errorf("isConnectionError(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,215 @@
+package internal
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	pluginv1 "github.com/orchestra-mcp/gen-go/orchestra/plugin/v1"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// ResourceCacheConfig bounds the cache of resources/read results. A zero
+// limit means unlimited.
+type ResourceCacheConfig struct {
+	MaxEntries int           // cached storage entries
+	MaxBytes   int64         // summed content size of cached entries
+	TTL        time.Duration // how long an entry is served before it is read again
+}
+
+// ResourceCacheStats reports the use of the resource read cache.
+type ResourceCacheStats struct {
+	Hits          int64 // reads served from the cache
+	Misses        int64 // reads sent to storage
+	Evictions     int64 // entries dropped to stay within the limits
+	Invalidations int64 // entries dropped because a storage event changed them
+	Entries       int   // entries currently cached
+	Bytes         int64 // summed content size of the cached entries
+}
+
+// resourceCache is an LRU cache of StorageRead responses keyed by storage
+// path. Entries are dropped when storage events report a change, unless the
+// event names the version already cached, much like an HTTP ETag match.
+type resourceCache struct {
+	cfg ResourceCacheConfig
+	now func() time.Time
+
+	mu      sync.Mutex
+	lru     *list.List               // of *cachedRead, most recently used first
+	entries map[string]*list.Element // by storage path
+	epoch   uint64                   // counts invalidations; see put
+	stats   ResourceCacheStats
+}
+
+// cachedRead is a cached StorageRead response.
+type cachedRead struct {
+	path    string
+	resp    *pluginv1.StorageReadResponse
+	expires time.Time // zero if the entry does not expire
+}
+
+func newResourceCache(cfg ResourceCacheConfig) *resourceCache {
+	return &resourceCache{
+		cfg:     cfg,
+		now:     time.Now,
+		lru:     list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// WithResourceCache caches resources/read results in memory. Cached entries
+// are dropped when storage.write or storage.delete events from the event
+// channel report a change, and are read again once the TTL passes. A
+// transport without an event channel only caches reads if the TTL is set,
+// since nothing else would ever drop an entry. Transports created with the
+// same option value share the cache, so the sessions of an HTTP endpoint
+// benefit from each other's reads.
+func WithResourceCache(cfg ResourceCacheConfig) func(*StdioTransport) {
+	c := newResourceCache(cfg)
+	return func(t *StdioTransport) {
+		t.resourceCache = c
+	}
+}
+
+// ResourceCacheStats returns the transport's resource read cache statistics.
+func (t *StdioTransport) ResourceCacheStats() ResourceCacheStats {
+	if t.resourceCache == nil {
+		return ResourceCacheStats{}
+	}
+	return t.resourceCache.snapshot()
+}
+
+// readStorage reads a storage entry, from the cache when possible; see
+// WithResourceCache. A nil response without an error means storage answered
+// with another response type.
+func (t *StdioTransport) readStorage(ctx context.Context, id any, storagePath string) (*pluginv1.StorageReadResponse, error) {
+	c := t.resourceCache
+	if t.eventCh == nil && c != nil && c.cfg.TTL == 0 {
+		c = nil
+	}
+	if sr, ok := c.get(storagePath); ok {
+		return sr, nil
+	}
+	epoch := c.currentEpoch()
+
+	resp, err := t.send(ctx, &pluginv1.PluginRequest{
+		RequestId: t.requestID("rr", id),
+		Request: &pluginv1.PluginRequest_StorageRead{
+			StorageRead: &pluginv1.StorageReadRequest{
+				Path: storagePath,
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	sr := resp.GetStorageRead()
+	if sr != nil {
+		c.put(storagePath, sr, epoch)
+	}
+	return sr, nil
+}
+
+// invalidateStorageEvent drops the cache entry of the path named by a storage
+// event. A write event whose "version" matches the cached entry leaves it in
+// place.
+func (c *resourceCache) invalidateStorageEvent(topic string, payload *structpb.Struct) {
+	if c == nil {
+		return
+	}
+	fields := payload.GetFields()
+	path := fields["path"].GetStringValue()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.epoch++
+	el, ok := c.entries[path]
+	if !ok {
+		return
+	}
+	if v, ok := fields["version"]; ok && topic == storageWriteTopic {
+		if int64(v.GetNumberValue()) == el.Value.(*cachedRead).resp.GetVersion() {
+			return
+		}
+	}
+	c.remove(el)
+	c.stats.Invalidations++
+}
+
+// get returns the cached response for a storage path, counting a hit or a
+// miss. Expired entries are dropped.
+func (c *resourceCache) get(path string) (*pluginv1.StorageReadResponse, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[path]
+	if ok {
+		e := el.Value.(*cachedRead)
+		if e.expires.IsZero() || c.now().Before(e.expires) {
+			c.lru.MoveToFront(el)
+			c.stats.Hits++
+			return e.resp, true
+		}
+		c.remove(el)
+	}
+	c.stats.Misses++
+	return nil, false
+}
+
+// currentEpoch returns the invalidation count to pass to put.
+func (c *resourceCache) currentEpoch() uint64 {
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.epoch
+}
+
+// put caches a response read when the invalidation count was epoch. If an
+// invalidation happened since, the response may predate the change it
+// reported and is not cached.
+func (c *resourceCache) put(path string, resp *pluginv1.StorageReadResponse, epoch uint64) {
+	if c == nil {
+		return
+	}
+	size := int64(len(resp.GetContent()))
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.epoch != epoch || (c.cfg.MaxBytes > 0 && size > c.cfg.MaxBytes) {
+		return
+	}
+	if el, ok := c.entries[path]; ok {
+		c.remove(el)
+	}
+
+	e := &cachedRead{path: path, resp: resp}
+	if c.cfg.TTL > 0 {
+		e.expires = c.now().Add(c.cfg.TTL)
+	}
+	c.entries[path] = c.lru.PushFront(e)
+	c.stats.Entries++
+	c.stats.Bytes += size
+
+	for (c.cfg.MaxEntries > 0 && c.stats.Entries > c.cfg.MaxEntries) || (c.cfg.MaxBytes > 0 && c.stats.Bytes > c.cfg.MaxBytes) {
+		c.remove(c.lru.Back())
+		c.stats.Evictions++
+	}
+}
+
+// remove drops an entry. The caller holds c.mu.
+func (c *resourceCache) remove(el *list.Element) {
+	e := c.lru.Remove(el).(*cachedRead)
+	delete(c.entries, e.path)
+	c.stats.Entries--
+	c.stats.Bytes -= int64(len(e.resp.GetContent()))
+}
+
+func (c *resourceCache) snapshot() ResourceCacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stats
+}
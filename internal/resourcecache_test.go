@@ -0,0 +1,159 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	pluginv1 "github.com/orchestra-mcp/gen-go/orchestra/plugin/v1"
+	"github.com/orchestra-mcp/sdk-go/protocol"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// countingStorage answers StorageRead requests with the path and the number of
+// reads so far, at version 1.
+func countingStorage(reads *atomic.Int64) *mockSender {
+	return &mockSender{
+		sendFunc: func(ctx context.Context, req *pluginv1.PluginRequest) (*pluginv1.PluginResponse, error) {
+			n := reads.Add(1)
+			return &pluginv1.PluginResponse{
+				Response: &pluginv1.PluginResponse_StorageRead{
+					StorageRead: &pluginv1.StorageReadResponse{
+						Content: fmt.Appendf(nil, "%s #%d", req.GetStorageRead().GetPath(), n),
+						Version: 1,
+					},
+				},
+			}, nil
+		},
+	}
+}
+
+// readText reads a resource and returns its text.
+func readText(t *testing.T, tr *StdioTransport, uri string) string {
+	t.Helper()
+	resp := roundTrip(t, tr.dispatch(context.Background(), &protocol.JSONRPCRequest{
+		JSONRPC: "2.0", ID: 1, Method: "resources/read", Params: json.RawMessage(`{"uri":"` + uri + `"}`),
+	}))
+	if resp.Error != nil {
+		t.Fatalf("read %s: %+v", uri, resp.Error)
+	}
+	return resp.Result.(map[string]any)["contents"].([]any)[0].(map[string]any)["text"].(string)
+}
+
+// versionedStorageEvent builds a storage.write event for path reporting the
+// version written.
+func versionedStorageEvent(t *testing.T, path string, version int) *pluginv1.EventDelivery {
+	t.Helper()
+	s, err := structpb.NewStruct(map[string]any{"path": path, "version": version})
+	if err != nil {
+		t.Fatalf("storage payload: %v", err)
+	}
+	return &pluginv1.EventDelivery{Topic: storageWriteTopic, Payload: s}
+}
+
+func TestResourceCacheServesRepeatedReads(t *testing.T) {
+	var reads atomic.Int64
+	tr := newReadyTransport(t, countingStorage(&reads), nil, nil, withEvents(), WithResourceCache(ResourceCacheConfig{MaxEntries: 8}))
+
+	for range 3 {
+		if got := readText(t, tr, "orchestra://features/FEAT-1"); got != "features/FEAT-1.md #1" {
+			t.Errorf("unexpected content %q", got)
+		}
+	}
+	if reads.Load() != 1 {
+		t.Errorf("expected one storage read, got %d", reads.Load())
+	}
+	stats := tr.ResourceCacheStats()
+	if stats.Hits != 2 || stats.Misses != 1 || stats.Entries != 1 || stats.Bytes != int64(len("features/FEAT-1.md #1")) {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestResourceCacheInvalidatedByStorageEvents(t *testing.T) {
+	var reads atomic.Int64
+	tr := newReadyTransport(t, countingStorage(&reads), nil, io.Discard, withEvents(), WithResourceCache(ResourceCacheConfig{}))
+	readText(t, tr, "orchestra://features/FEAT-1")
+
+	// A write of the cached version, like a matching ETag, keeps the entry.
+	tr.handleEvent(versionedStorageEvent(t, "features/FEAT-1.md", 1))
+	if got := readText(t, tr, "orchestra://features/FEAT-1"); got != "features/FEAT-1.md #1" {
+		t.Errorf("expected cached content after a same-version write, got %q", got)
+	}
+
+	tr.handleEvent(versionedStorageEvent(t, "features/FEAT-1.md", 2))
+	if got := readText(t, tr, "orchestra://features/FEAT-1"); got != "features/FEAT-1.md #2" {
+		t.Errorf("expected a fresh read after a write, got %q", got)
+	}
+
+	tr.handleEvent(storageEvent(t, storageDeleteTopic, "features/FEAT-1.md", false))
+	if got := readText(t, tr, "orchestra://features/FEAT-1"); got != "features/FEAT-1.md #3" {
+		t.Errorf("expected a fresh read after a delete, got %q", got)
+	}
+	if stats := tr.ResourceCacheStats(); stats.Invalidations != 2 {
+		t.Errorf("expected 2 invalidations, got %+v", stats)
+	}
+}
+
+func TestResourceCacheLimits(t *testing.T) {
+	c := newResourceCache(ResourceCacheConfig{MaxEntries: 2, MaxBytes: 10, TTL: time.Minute})
+	now := time.Now()
+	c.now = func() time.Time { return now }
+	read := func(content string) *pluginv1.StorageReadResponse {
+		return &pluginv1.StorageReadResponse{Content: []byte(content)}
+	}
+
+	c.put("a", read("aaaa"), 0)
+	c.put("b", read("bbbb"), 0)
+	c.get("a")
+	c.put("c", read("cccc"), 0) // evicts b, the least recently used
+	if _, ok := c.get("b"); ok {
+		t.Error("expected b to be evicted")
+	}
+	if _, ok := c.get("a"); !ok {
+		t.Error("expected a to be cached")
+	}
+
+	c.put("d", read("ddddddd"), 0) // 4+7 bytes exceed MaxBytes; evicts c and a
+	if stats := c.snapshot(); stats.Entries != 1 || stats.Bytes != 7 || stats.Evictions != 3 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+	c.put("e", read("eeeeeeeeeee"), 0) // larger than MaxBytes; not cached
+	if _, ok := c.get("e"); ok {
+		t.Error("expected an oversized entry not to be cached")
+	}
+
+	now = now.Add(2 * time.Minute)
+	if _, ok := c.get("d"); ok {
+		t.Error("expected d to expire")
+	}
+
+	// A read that raced with an invalidation is not cached.
+	epoch := c.currentEpoch()
+	c.invalidateStorageEvent(storageWriteTopic, nil)
+	c.put("f", read("f"), epoch)
+	if _, ok := c.get("f"); ok {
+		t.Error("expected a read older than an invalidation not to be cached")
+	}
+}
+
+func TestResourceCacheNeedsEventsOrTTL(t *testing.T) {
+	var reads atomic.Int64
+	tr := newReadyTransport(t, countingStorage(&reads), nil, nil, WithResourceCache(ResourceCacheConfig{}))
+	readText(t, tr, "orchestra://features/FEAT-1")
+	readText(t, tr, "orchestra://features/FEAT-1")
+	if reads.Load() != 2 {
+		t.Errorf("without events or a TTL reads must not be cached: got %d storage reads, want 2", reads.Load())
+	}
+
+	reads.Store(0)
+	tr = newReadyTransport(t, countingStorage(&reads), nil, nil, WithResourceCache(ResourceCacheConfig{TTL: time.Minute}))
+	readText(t, tr, "orchestra://features/FEAT-1")
+	readText(t, tr, "orchestra://features/FEAT-1")
+	if reads.Load() != 1 {
+		t.Errorf("with a TTL reads are cached: got %d storage reads, want 1", reads.Load())
+	}
+}
@@ -0,0 +1,138 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	pluginv1 "github.com/orchestra-mcp/gen-go/orchestra/plugin/v1"
+	"github.com/orchestra-mcp/sdk-go/protocol"
+)
+
+// maxDescriptionReads bounds the StorageRead calls a resources/list request
+// makes at once to describe its resources.
+const maxDescriptionReads = 8
+
+// Resource is the JSON shape of a resource in a resources/list response.
+// Size, LastModified and Description are set when storage provides them.
+type Resource struct {
+	URI         string               `json:"uri"`
+	Name        string               `json:"name"`
+	Description string               `json:"description,omitempty"`
+	MimeType    string               `json:"mimeType,omitempty"`
+	Size        int64                `json:"size,omitempty"`
+	Annotations *ResourceAnnotations `json:"annotations,omitempty"`
+}
+
+// ResourceAnnotations are MCP hints about a resource.
+type ResourceAnnotations struct {
+	LastModified string `json:"lastModified,omitempty"` // RFC 3339
+}
+
+// resourcesListMeta is the "_meta" of a resources/list response that is
+// missing the resources of some namespaces.
+type resourcesListMeta struct {
+	Errors []namespaceError `json:"errors"`
+}
+
+// namespaceError reports a namespace whose resources could not be listed.
+type namespaceError struct {
+	Namespace string `json:"namespace"`
+	Error     string `json:"error"`
+}
+
+// namespaceListing is the outcome of listing one namespace.
+type namespaceListing struct {
+	entries []*pluginv1.StorageEntry
+	err     error
+}
+
+// listNamespaces lists the entries of every namespace concurrently, under the
+// deadline of the resources/list request. The listings are in namespace order.
+func (t *StdioTransport) listNamespaces(ctx context.Context, id any) []namespaceListing {
+	listings := make([]namespaceListing, len(t.namespaces))
+	var wg sync.WaitGroup
+	for i, ns := range t.namespaces {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := t.send(ctx, &pluginv1.PluginRequest{
+				RequestId: t.requestID("rl", fmt.Sprintf("%v-%s", id, ns.Scheme)),
+				Request: &pluginv1.PluginRequest_StorageList{
+					StorageList: &pluginv1.StorageListRequest{
+						Prefix: ns.Prefix,
+					},
+				},
+			})
+			if err == nil && resp.GetStorageList() == nil {
+				err = errors.New("unexpected response type from storage")
+			}
+			listings[i] = namespaceListing{entries: resp.GetStorageList().GetEntries(), err: err}
+		}()
+	}
+	wg.Wait()
+	return listings
+}
+
+// listedResource describes the storage entry at a resource URI.
+func (t *StdioTransport) listedResource(ns ResourceNamespace, uri, name string, entry *pluginv1.StorageEntry) Resource {
+	r := Resource{
+		URI:      uri,
+		Name:     name,
+		MimeType: ns.listMimeType(entry.GetPath()),
+		Size:     entry.GetSize(),
+	}
+	if ts := entry.GetModifiedAt(); ts != nil && t.supports(featureResourceLastModified) {
+		r.Annotations = &ResourceAnnotations{LastModified: ts.AsTime().UTC().Format(time.RFC3339)}
+	}
+	return r
+}
+
+// reportListErrors warns the client about namespaces that could not be
+// listed and returns them for the response's _meta.
+func (t *StdioTransport) reportListErrors(listings []namespaceListing) *resourcesListMeta {
+	var meta *resourcesListMeta
+	for i, l := range listings {
+		if l.err == nil {
+			continue
+		}
+		ns := t.namespaces[i]
+		if meta == nil {
+			meta = &resourcesListMeta{}
+		}
+		meta.Errors = append(meta.Errors, namespaceError{Namespace: ns.Scheme, Error: l.err.Error()})
+		t.SendLogNotification(protocol.LogLevelWarning, transportPluginID,
+			fmt.Sprintf("resources/list: %s resources are missing: listing %s failed: %v", ns.Scheme, ns.Prefix, l.err))
+	}
+	return meta
+}
+
+// describeResources sets the description of each resource from the front
+// matter in its storage metadata, reading the entries at storagePaths
+// concurrently. StorageList does not return metadata, so each entry is read,
+// from the resource cache when possible. A resource whose entry cannot be
+// read is listed without a description.
+func (t *StdioTransport) describeResources(ctx context.Context, id any, resources []Resource, storagePaths []string) {
+	sem := make(chan struct{}, maxDescriptionReads)
+	var wg sync.WaitGroup
+	for i := range resources {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			sr, err := t.readStorage(ctx, fmt.Sprintf("%v-%d", id, i), storagePaths[i])
+			if err != nil {
+				slog.Debug("reading resource description failed", "path", storagePaths[i], "error", err)
+				return
+			}
+			resources[i].Description = sr.GetMetadata().GetFields()["description"].GetStringValue()
+		}()
+	}
+	wg.Wait()
+}
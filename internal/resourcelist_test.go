@@ -0,0 +1,192 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	pluginv1 "github.com/orchestra-mcp/gen-go/orchestra/plugin/v1"
+	"github.com/orchestra-mcp/sdk-go/protocol"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func listResources(t *testing.T, tr *StdioTransport) protocol.JSONRPCResponse {
+	t.Helper()
+	resp := roundTrip(t, tr.dispatch(context.Background(), &protocol.JSONRPCRequest{JSONRPC: "2.0", ID: 1, Method: "resources/list"}))
+	if resp.Error != nil {
+		t.Fatalf("resources/list: %+v", resp.Error)
+	}
+	return resp
+}
+
+func TestResourcesListQueriesNamespacesConcurrently(t *testing.T) {
+	// Each StorageList waits until all namespaces have been asked, which
+	// only happens if the calls run at the same time.
+	var arrived sync.WaitGroup
+	arrived.Add(len(defaultResourceNamespaces))
+	all := make(chan struct{})
+	go func() { arrived.Wait(); close(all) }()
+
+	sender := &mockSender{
+		sendFunc: func(ctx context.Context, req *pluginv1.PluginRequest) (*pluginv1.PluginResponse, error) {
+			if req.GetStorageList() == nil {
+				return &pluginv1.PluginResponse{}, nil
+			}
+			arrived.Done()
+			select {
+			case <-all:
+			case <-time.After(5 * time.Second):
+				return nil, errors.New("namespaces were listed one at a time")
+			}
+			prefix := req.GetStorageList().GetPrefix()
+			return &pluginv1.PluginResponse{
+				Response: &pluginv1.PluginResponse_StorageList{
+					StorageList: &pluginv1.StorageListResponse{
+						Entries: []*pluginv1.StorageEntry{{Path: prefix + "a.md"}},
+					},
+				},
+			}, nil
+		},
+	}
+	tr := newReadyTransport(t, sender, nil, nil)
+
+	resources := listResources(t, tr).Result.(map[string]any)["resources"].([]any)
+	var uris []string
+	for _, r := range resources {
+		uris = append(uris, r.(map[string]any)["uri"].(string))
+	}
+	// Results keep the namespace order.
+	if strings.Join(uris, " ") != "orchestra://features/a orchestra://notes/a orchestra://docs/a" {
+		t.Errorf("unexpected resources: %v", uris)
+	}
+}
+
+func TestResourcesListReportsFailedNamespaces(t *testing.T) {
+	sender := &mockSender{
+		sendFunc: func(ctx context.Context, req *pluginv1.PluginRequest) (*pluginv1.PluginResponse, error) {
+			prefix := req.GetStorageList().GetPrefix()
+			if prefix == "notes/" {
+				return nil, errors.New("storage plugin unavailable")
+			}
+			return &pluginv1.PluginResponse{
+				Response: &pluginv1.PluginResponse_StorageList{
+					StorageList: &pluginv1.StorageListResponse{
+						Entries: []*pluginv1.StorageEntry{{Path: prefix + "a.md"}},
+					},
+				},
+			}, nil
+		},
+	}
+	var out bytes.Buffer
+	tr := newReadyTransport(t, sender, nil, &out)
+
+	result := listResources(t, tr).Result.(map[string]any)
+	if n := len(result["resources"].([]any)); n != 2 {
+		t.Errorf("expected the resources of the other namespaces, got %d", n)
+	}
+	raw, _ := json.Marshal(result["_meta"])
+	var meta resourcesListMeta
+	json.Unmarshal(raw, &meta)
+	if len(meta.Errors) != 1 || meta.Errors[0].Namespace != "notes" || !strings.Contains(meta.Errors[0].Error, "storage plugin unavailable") {
+		t.Errorf("unexpected _meta: %s", raw)
+	}
+
+	var notif struct {
+		Method string `json:"method"`
+		Params struct {
+			Level string `json:"level"`
+			Data  string `json:"data"`
+		} `json:"params"`
+	}
+	if err := json.Unmarshal(out.Bytes(), &notif); err != nil {
+		t.Fatalf("parse notification %q: %v", out.String(), err)
+	}
+	if notif.Method != "notifications/message" || notif.Params.Level != "warning" || !strings.Contains(notif.Params.Data, "notes/") {
+		t.Errorf("expected a warning about notes/, got %+v", notif)
+	}
+}
+
+func TestResourcesListMetadata(t *testing.T) {
+	modified := time.Date(2025, 7, 1, 12, 30, 0, 0, time.UTC)
+	sender := &mockSender{
+		sendFunc: func(ctx context.Context, req *pluginv1.PluginRequest) (*pluginv1.PluginResponse, error) {
+			if req.GetStorageRead() != nil {
+				md, _ := structpb.NewStruct(map[string]any{"description": "Login and tokens"})
+				return &pluginv1.PluginResponse{
+					Response: &pluginv1.PluginResponse_StorageRead{
+						StorageRead: &pluginv1.StorageReadResponse{Content: []byte("---\ndescription: Login and tokens\n---\n# Auth"), Metadata: md},
+					},
+				}, nil
+			}
+			list := &pluginv1.StorageListResponse{}
+			if req.GetStorageList().GetPrefix() == "docs/" {
+				list.Entries = []*pluginv1.StorageEntry{{Path: "docs/auth.md", Size: 42, ModifiedAt: timestamppb.New(modified)}}
+			}
+			return &pluginv1.PluginResponse{Response: &pluginv1.PluginResponse_StorageList{StorageList: list}}, nil
+		},
+	}
+	tr := newReadyTransport(t, sender, nil, nil)
+
+	r := listResources(t, tr).Result.(map[string]any)["resources"].([]any)[0].(map[string]any)
+	if r["size"] != float64(42) || r["annotations"].(map[string]any)["lastModified"] != "2025-07-01T12:30:00Z" {
+		t.Errorf("expected size and lastModified, got %v", r)
+	}
+	if r["description"] != "Login and tokens" {
+		t.Errorf("expected the front matter description, got %v", r)
+	}
+
+	// Clients of older protocol versions do not get lastModified.
+	old := NewStdioTransport(sender, nil, nil)
+	old.dispatch(context.Background(), &protocol.JSONRPCRequest{
+		JSONRPC: "2.0", ID: 0, Method: "initialize", Params: json.RawMessage(`{"protocolVersion":"2025-03-26"}`),
+	})
+	old.dispatch(context.Background(), &protocol.JSONRPCRequest{JSONRPC: "2.0", Method: "notifications/initialized"})
+	r = listResources(t, old).Result.(map[string]any)["resources"].([]any)[0].(map[string]any)
+	if _, ok := r["annotations"]; ok || r["size"] != float64(42) {
+		t.Errorf("expected size without annotations, got %v", r)
+	}
+}
+
+func TestResourcesListDescribesOnlyThePage(t *testing.T) {
+	files := map[string]string{"notes/a.md": "# A", "notes/b.md": "# B", "notes/c.md": "# C"}
+	var reads []string
+	tr := newReadyTransport(t, storageSender(files, &reads), nil, nil, WithPageSize(2))
+
+	if n := len(listResources(t, tr).Result.(map[string]any)["resources"].([]any)); n != 2 {
+		t.Fatalf("expected a page of 2 resources, got %d", n)
+	}
+	slices.Sort(reads)
+	if strings.Join(reads, " ") != "notes/a.md notes/b.md" {
+		t.Errorf("expected only the listed page to be read, got %v", reads)
+	}
+}
+
+func TestResourcesListUsesMethodTimeout(t *testing.T) {
+	sender := &mockSender{
+		sendFunc: func(ctx context.Context, req *pluginv1.PluginRequest) (*pluginv1.PluginResponse, error) {
+			if req.GetStorageList().GetPrefix() == "notes/" {
+				<-ctx.Done()
+				return nil, ctx.Err()
+			}
+			return &pluginv1.PluginResponse{
+				Response: &pluginv1.PluginResponse_StorageList{StorageList: &pluginv1.StorageListResponse{}},
+			}, nil
+		},
+	}
+	tr := newReadyTransport(t, sender, nil, io.Discard, WithRequestTimeouts(RequestTimeouts{
+		PerMethod: map[string]time.Duration{"resources/list": 20 * time.Millisecond},
+	}))
+
+	raw, _ := json.Marshal(listResources(t, tr).Result.(map[string]any)["_meta"])
+	if !strings.Contains(string(raw), "resources/list timed out after 20ms") {
+		t.Errorf("expected the notes namespace to time out with the method, got %s", raw)
+	}
+}
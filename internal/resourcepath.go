@@ -0,0 +1,80 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// errInvalidResourceID is returned for resource IDs that do not name an entry
+// inside their namespace.
+var errInvalidResourceID = errors.New("invalid resource ID")
+
+// decodeResourceID percent-decodes the ID part of a resource URI, such as
+// "api/auth%20flow" in orchestra://docs/api/auth%20flow, one path segment at a
+// time, and checks the result with checkResourceID.
+func decodeResourceID(raw string) (string, error) {
+	if strings.ContainsAny(raw, "?#") {
+		return "", fmt.Errorf("%w %q: resource URIs take no query or fragment", errInvalidResourceID, raw)
+	}
+	segments := strings.Split(raw, "/")
+	for i, seg := range segments {
+		dec, err := url.PathUnescape(seg)
+		if err != nil {
+			return "", fmt.Errorf("%w %q: %v", errInvalidResourceID, raw, err)
+		}
+		if strings.Contains(dec, "/") {
+			return "", fmt.Errorf("%w %q: encoded '/' in path segment", errInvalidResourceID, raw)
+		}
+		segments[i] = dec
+	}
+	id := strings.Join(segments, "/")
+	if err := checkResourceID(id); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// checkResourceID checks that a decoded resource ID is a relative path of
+// non-empty segments that stays inside its namespace: no ".." or "." segments,
+// no leading '/', no backslashes and no control characters.
+func checkResourceID(id string) error {
+	if id == "" {
+		return fmt.Errorf("%w: empty", errInvalidResourceID)
+	}
+	if !utf8.ValidString(id) {
+		return fmt.Errorf("%w %q: not valid UTF-8", errInvalidResourceID, id)
+	}
+	if strings.IndexFunc(id, unicode.IsControl) >= 0 {
+		return fmt.Errorf("%w %q: contains control characters", errInvalidResourceID, id)
+	}
+	if strings.HasPrefix(id, "/") {
+		return fmt.Errorf("%w %q: absolute path", errInvalidResourceID, id)
+	}
+	if strings.Contains(id, `\`) {
+		return fmt.Errorf("%w %q: contains a backslash", errInvalidResourceID, id)
+	}
+	for seg := range strings.SplitSeq(id, "/") {
+		switch seg {
+		case "":
+			return fmt.Errorf("%w %q: empty path segment", errInvalidResourceID, id)
+		case ".", "..":
+			return fmt.Errorf("%w %q: path traversal", errInvalidResourceID, id)
+		}
+	}
+	return nil
+}
+
+// escapeResourceID percent-encodes a resource ID for use in a URI, keeping the
+// '/' between segments. It is the inverse of decodeResourceID and yields the
+// canonical form of the ID.
+func escapeResourceID(id string) string {
+	segments := strings.Split(id, "/")
+	for i, seg := range segments {
+		segments[i] = url.PathEscape(seg)
+	}
+	return strings.Join(segments, "/")
+}
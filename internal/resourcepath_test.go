@@ -0,0 +1,139 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	pluginv1 "github.com/orchestra-mcp/gen-go/orchestra/plugin/v1"
+	"github.com/orchestra-mcp/sdk-go/protocol"
+)
+
+func TestDecodeResourceID(t *testing.T) {
+	tests := []struct {
+		raw, want string
+		wantErr   bool
+	}{
+		{"FEAT-ABC", "FEAT-ABC", false},
+		{"api/auth", "api/auth", false},
+		{"api/auth%20flow", "api/auth flow", false},
+		{"%E6%97%A5%E6%9C%AC", "日本", false},
+		{"../secrets", "", true},
+		{"api/../../secrets", "", true},
+		{"%2E%2E/secrets", "", true},
+		{"./a", "", true},
+		{"/etc/passwd", "", true},
+		{"%2Fetc%2Fpasswd", "", true},
+		{"api%2F..%2Fx", "", true},
+		{"a//b", "", true},
+		{"a/", "", true},
+		{`..\secrets`, "", true},
+		{"a%00b", "", true},
+		{"a%0Ab", "", true},
+		{"a%ZZ", "", true},
+		{"%FF", "", true},
+		{"a?b=1", "", true},
+		{"a#frag", "", true},
+	}
+	for _, tt := range tests {
+		got, err := decodeResourceID(tt.raw)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("decodeResourceID(%q) = %q, %v; want %q, error %v", tt.raw, got, err, tt.want, tt.wantErr)
+		}
+		if err != nil && !errors.Is(err, errInvalidResourceID) {
+			t.Errorf("decodeResourceID(%q): error %v is not errInvalidResourceID", tt.raw, err)
+		}
+	}
+}
+
+func TestResourcesReadRejectsTraversalBeforeStorage(t *testing.T) {
+	sender := &mockSender{
+		sendFunc: func(ctx context.Context, req *pluginv1.PluginRequest) (*pluginv1.PluginResponse, error) {
+			t.Errorf("unexpected storage request for %s", req.GetStorageRead().GetPath())
+			return nil, errors.New("unexpected request")
+		},
+	}
+	tr := newReadyTransport(t, sender, nil, nil)
+
+	for _, uri := range []string{
+		"orchestra://notes/../secrets",
+		"orchestra://notes/%2e%2e/secrets",
+		"orchestra://notes//etc/passwd",
+		"orchestra://notes/a%00",
+	} {
+		resp := roundTrip(t, tr.dispatch(context.Background(), &protocol.JSONRPCRequest{
+			JSONRPC: "2.0", ID: 1, Method: "resources/read", Params: []byte(`{"uri":"` + uri + `"}`),
+		}))
+		if resp.Error == nil || resp.Error.Code != protocol.InvalidParams {
+			t.Errorf("%s: expected InvalidParams, got %+v", uri, resp)
+		}
+	}
+}
+
+func TestNestedResources(t *testing.T) {
+	files := map[string]string{
+		"docs/api/auth.md":        "# Auth",
+		"docs/api/auth flow.md":   "# Flow",
+		"docs/guides/setup/a.md":  "# Setup",
+		"docs/../outside.md":      "listed by a broken storage plugin",
+		"docs/api/":               "",
+		"docs/diagrams/arch.png":  "png",
+		"features/FEAT-1/spec.md": "# Spec",
+	}
+	var reads []string
+	tr := newReadyTransport(t, storageSender(files, &reads), nil, nil)
+	ctx := context.Background()
+
+	resp := roundTrip(t, tr.dispatch(ctx, &protocol.JSONRPCRequest{JSONRPC: "2.0", ID: 1, Method: "resources/list"}))
+	got := map[string]string{}
+	for _, r := range resp.Result.(map[string]any)["resources"].([]any) {
+		r := r.(map[string]any)
+		got[r["uri"].(string)] = r["name"].(string)
+	}
+	want := map[string]string{
+		"orchestra://docs/api/auth":          "api/auth",
+		"orchestra://docs/api/auth%20flow":   "api/auth flow",
+		"orchestra://docs/guides/setup/a":    "guides/setup/a",
+		"orchestra://docs/diagrams/arch.png": "diagrams/arch.png",
+		"orchestra://features/FEAT-1/spec":   "FEAT-1/spec",
+	}
+	if len(got) != len(want) {
+		t.Errorf("resources: got %v, want %v", got, want)
+	}
+	for uri, name := range want {
+		if got[uri] != name {
+			t.Errorf("%s: got name %q, want %q", uri, got[uri], name)
+		}
+	}
+	reads = nil // listing read each resource for its description
+
+	for uri := range want {
+		resp := roundTrip(t, tr.dispatch(ctx, &protocol.JSONRPCRequest{
+			JSONRPC: "2.0", ID: 2, Method: "resources/read", Params: []byte(`{"uri":"` + uri + `"}`),
+		}))
+		if resp.Error != nil {
+			t.Errorf("read %s: %+v", uri, resp.Error)
+		}
+	}
+	if len(reads) != len(want) {
+		t.Errorf("expected %d storage reads, got %v", len(want), reads)
+	}
+	for _, path := range reads {
+		if _, ok := files[path]; !ok {
+			t.Errorf("read unexpected storage path %q", path)
+		}
+	}
+}
+
+func TestSubscribeUsesCanonicalURI(t *testing.T) {
+	tr := newReadyTransport(t, &mockSender{}, nil, nil)
+	resp := roundTrip(t, tr.dispatch(context.Background(), &protocol.JSONRPCRequest{
+		JSONRPC: "2.0", ID: 1, Method: "resources/subscribe", Params: []byte(`{"uri":"orchestra://docs/api/auth%66low"}`),
+	}))
+	if resp.Error != nil {
+		t.Fatalf("subscribe: %+v", resp.Error)
+	}
+	if uri, _, _ := tr.resourceURIForPath("docs/api/authflow.md"); !tr.isSubscribed(uri) {
+		t.Errorf("expected a subscription to %s", uri)
+	}
+}
@@ -0,0 +1,98 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"slices"
+)
+
+// rootsChangedTopic is the orchestrator event topic on which the transport
+// publishes the client's workspace roots whenever it learns them. Tool calls
+// carry the same session ID in ToolRequest.session_id, so plugins can look up
+// the roots of the session a call came from:
+//
+//	{"session_id": "5f0c...", "roots": [{"uri": "file:///home/me/project", "name": "project"}]}
+const rootsChangedTopic = "client.roots.changed"
+
+// Root is a workspace folder the client has open.
+type Root struct {
+	URI  string `json:"uri"`
+	Name string `json:"name,omitempty"`
+}
+
+// rootsListResult is the JSON shape of a roots/list response.
+type rootsListResult struct {
+	Roots []Root `json:"roots"`
+}
+
+// Roots returns the client's workspace roots as last reported. It is empty
+// until the client has answered roots/list, and always empty for clients
+// without the roots capability.
+func (t *StdioTransport) Roots() []Root {
+	t.rootsMu.Lock()
+	defer t.rootsMu.Unlock()
+	return slices.Clone(t.roots)
+}
+
+// refreshRoots asks the client for its roots in the background, if it
+// supports roots. It is called once the handshake completes and whenever the
+// client reports that its roots changed.
+func (t *StdioTransport) refreshRoots() {
+	// Capabilities are only read once the handshake has completed.
+	if t.State() != StateReady || t.clientCaps.Roots == nil {
+		return
+	}
+
+	t.rootsMu.Lock()
+	t.rootsGen++
+	gen := t.rootsGen
+	t.rootsMu.Unlock()
+
+	t.background(func() {
+		roots, err := t.listRoots(context.Background())
+		if err != nil {
+			slog.Debug("roots/list failed", "error", err)
+			return
+		}
+
+		// A newer refresh may have been started while this one waited for
+		// the client; only the latest answer is kept.
+		t.rootsMu.Lock()
+		if gen != t.rootsGen {
+			t.rootsMu.Unlock()
+			return
+		}
+		t.roots = roots
+		t.rootsMu.Unlock()
+
+		payload := map[string]any{"session_id": t.sessionID, "roots": rootsPayload(roots)}
+		if err := t.publish(rootsChangedTopic, "roots/list", payload); err != nil {
+			slog.Error("failed publishing roots", "error", err)
+		}
+	})
+}
+
+// listRoots sends roots/list to the client and returns the roots it reports.
+// Roots without a URI are dropped.
+func (t *StdioTransport) listRoots(ctx context.Context) ([]Root, error) {
+	raw, err := t.requestClient(ctx, "roots/list", nil)
+	if err != nil {
+		return nil, err
+	}
+	var result rootsListResult
+	if err := json.Unmarshal(raw, &result); err != nil {
+		return nil, fmt.Errorf("invalid roots/list result: %w", err)
+	}
+	return slices.DeleteFunc(result.Roots, func(r Root) bool { return r.URI == "" }), nil
+}
+
+// rootsPayload converts roots to the generic form structpb accepts.
+func rootsPayload(roots []Root) []any {
+	out := make([]any, len(roots))
+	for i, r := range roots {
+		out[i] = map[string]any{"uri": r.URI, "name": r.Name}
+	}
+	return out
+}
@@ -0,0 +1,57 @@
+package internal
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// answerRootsList reads the transport's roots/list request and answers it.
+func answerRootsList(t *testing.T, s *clientSession, roots string) {
+	t.Helper()
+	var req clientRequest
+	if err := json.Unmarshal([]byte(s.out.next(t)), &req); err != nil {
+		t.Fatalf("parse request: %v", err)
+	}
+	if req.Method != "roots/list" {
+		t.Fatalf("expected roots/list, got %+v", req)
+	}
+	s.send(t, `{"jsonrpc":"2.0","id":"`+req.ID+`","result":{"roots":`+roots+`}}`)
+}
+
+func TestRootsListedAndRefreshed(t *testing.T) {
+	sender, published := publishRecorder()
+	s := startClientSession(t, sender, `{"roots":{"listChanged":true}}`)
+
+	answerRootsList(t, s, `[{"uri":"file:///work/a","name":"a"},{"name":"no uri"}]`)
+	msg := waitPublished(t, published)
+	if msg["session_id"] != s.tr.sessionID {
+		t.Errorf("session_id: got %v, want %q", msg["session_id"], s.tr.sessionID)
+	}
+	if roots, _ := msg["roots"].([]any); len(roots) != 1 {
+		t.Errorf("expected the root without a URI to be dropped, got %v", msg["roots"])
+	}
+	if got := s.tr.Roots(); len(got) != 1 || got[0].URI != "file:///work/a" {
+		t.Errorf("Roots: got %+v", got)
+	}
+
+	s.send(t, `{"jsonrpc":"2.0","method":"notifications/roots/list_changed"}`)
+	answerRootsList(t, s, `[{"uri":"file:///work/b"}]`)
+	waitPublished(t, published)
+	if got := s.tr.Roots(); len(got) != 1 || got[0].URI != "file:///work/b" {
+		t.Errorf("Roots after list_changed: got %+v", got)
+	}
+}
+
+func TestRootsNotRequestedWithoutCapability(t *testing.T) {
+	sender, _ := publishRecorder()
+	s := startClientSession(t, sender, `{}`)
+
+	s.send(t, `{"jsonrpc":"2.0","method":"notifications/roots/list_changed"}`)
+	s.send(t, `{"jsonrpc":"2.0","id":1,"method":"ping"}`)
+	if resp := parseJSONRPCResponse(t, s.out.next(t)); resp.ID != float64(1) {
+		t.Errorf("expected only the ping response, got %+v", resp)
+	}
+	if got := s.tr.Roots(); len(got) != 0 {
+		t.Errorf("expected no roots, got %+v", got)
+	}
+}
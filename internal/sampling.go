@@ -0,0 +1,55 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// samplingRequestTopic is the orchestrator event topic on which plugins ask the
+// connected client to run sampling/createMessage. The payload carries an ID
+// chosen by the plugin, the MCP request params and an optional timeout:
+//
+//	{"request_id": "ai-42", "timeout_ms": 60000,
+//	 "params": {"messages": [...], "maxTokens": 400}}
+//
+// The outcome is published on samplingResultTopic.
+const samplingRequestTopic = "client.sampling.request"
+
+// samplingResultTopic is the topic on which the transport publishes the outcome
+// of a sampling request. The payload echoes the request_id and names the
+// requesting plugin, with either the client's result or an error message:
+//
+//	{"request_id": "ai-42", "plugin": "tools.ai",
+//	 "result": {"role": "assistant", "content": {...}, "model": "..."}}
+//	{"request_id": "ai-42", "plugin": "tools.ai", "error": "..."}
+const samplingResultTopic = "client.sampling.result"
+
+// errSamplingUnsupported is returned for sampling requests on sessions whose
+// client did not advertise the sampling capability.
+var errSamplingUnsupported = errors.New("client does not support sampling")
+
+// createMessage asks the client to sample its LLM with the given
+// sampling/createMessage params.
+func (t *StdioTransport) createMessage(ctx context.Context, params *structpb.Struct) (map[string]any, error) {
+	// Capabilities are only read once the handshake has completed.
+	if t.State() != StateReady || t.clientCaps.Sampling == nil {
+		return nil, errSamplingUnsupported
+	}
+	if params == nil {
+		return nil, fmt.Errorf("sampling request missing params")
+	}
+
+	raw, err := t.requestClient(ctx, "sampling/createMessage", params.AsMap())
+	if err != nil {
+		return nil, err
+	}
+	var result map[string]any
+	if err := json.Unmarshal(raw, &result); err != nil {
+		return nil, fmt.Errorf("invalid sampling/createMessage result: %w", err)
+	}
+	return result, nil
+}
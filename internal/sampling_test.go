@@ -0,0 +1,264 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	pluginv1 "github.com/orchestra-mcp/gen-go/orchestra/plugin/v1"
+	"github.com/orchestra-mcp/sdk-go/protocol"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// publishRecorder returns a sender that forwards published events to a channel.
+func publishRecorder() (*mockSender, <-chan *pluginv1.Publish) {
+	published := make(chan *pluginv1.Publish, 4)
+	return &mockSender{
+		sendFunc: func(ctx context.Context, req *pluginv1.PluginRequest) (*pluginv1.PluginResponse, error) {
+			if p := req.GetPublish(); p != nil {
+				published <- p
+			}
+			return &pluginv1.PluginResponse{RequestId: req.RequestId}, nil
+		},
+	}, published
+}
+
+// clientSession is a running transport whose client declared the given
+// capabilities. The test plays the client by writing to in and reading out.
+type clientSession struct {
+	tr     *StdioTransport
+	in     *io.PipeWriter
+	out    *lineWriter
+	events chan *pluginv1.EventDelivery
+	done   chan error
+}
+
+func startClientSession(t *testing.T, sender Sender, capabilities string, opts ...func(*StdioTransport)) *clientSession {
+	t.Helper()
+	pr, pw := io.Pipe()
+	s := &clientSession{
+		in:     pw,
+		out:    newLineWriter(),
+		events: make(chan *pluginv1.EventDelivery, 4),
+		done:   make(chan error, 1),
+	}
+	tr := NewStdioTransport(sender, pr, s.out, append(opts, WithEventChannel(s.events))...)
+	s.tr = tr
+	ctx := context.Background()
+	resp := tr.dispatch(ctx, &protocol.JSONRPCRequest{
+		JSONRPC: "2.0", ID: 0, Method: "initialize",
+		Params: json.RawMessage(`{"protocolVersion":"2025-06-18","capabilities":` + capabilities + `}`),
+	})
+	if resp.Error != nil {
+		t.Fatalf("initialize: %+v", resp.Error)
+	}
+	tr.dispatch(ctx, &protocol.JSONRPCRequest{JSONRPC: "2.0", Method: "notifications/initialized"})
+
+	go func() { s.done <- tr.Run(ctx) }()
+	t.Cleanup(func() {
+		close(s.events)
+		pw.Close()
+		<-s.done
+	})
+	return s
+}
+
+// send writes one line from the client.
+func (s *clientSession) send(t *testing.T, line string) {
+	t.Helper()
+	if _, err := io.WriteString(s.in, line+"\n"); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+}
+
+func samplingEvent(t *testing.T, payload map[string]any) *pluginv1.EventDelivery {
+	t.Helper()
+	s, err := structpb.NewStruct(payload)
+	if err != nil {
+		t.Fatalf("sampling payload: %v", err)
+	}
+	return &pluginv1.EventDelivery{Topic: samplingRequestTopic, SourcePlugin: "tools.ai", Payload: s}
+}
+
+// waitPublished waits for the next event the transport publishes.
+func waitPublished(t *testing.T, published <-chan *pluginv1.Publish) map[string]any {
+	t.Helper()
+	select {
+	case p := <-published:
+		return p.GetPayload().AsMap()
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for a published event")
+		return nil
+	}
+}
+
+func TestSamplingRoundTrip(t *testing.T) {
+	sender, published := publishRecorder()
+	s := startClientSession(t, sender, `{"sampling":{}}`)
+
+	s.events <- samplingEvent(t, map[string]any{
+		"request_id": "ai-1",
+		"params": map[string]any{
+			"messages":  []any{map[string]any{"role": "user", "content": map[string]any{"type": "text", "text": "hi"}}},
+			"maxTokens": 50,
+		},
+	})
+
+	var req clientRequest
+	if err := json.Unmarshal([]byte(s.out.next(t)), &req); err != nil {
+		t.Fatalf("parse request: %v", err)
+	}
+	if req.Method != "sampling/createMessage" || req.ID == "" {
+		t.Fatalf("unexpected request: %+v", req)
+	}
+
+	s.send(t, `{"jsonrpc":"2.0","id":"`+req.ID+`","result":{"role":"assistant","content":{"type":"text","text":"hello"},"model":"m1"}}`)
+
+	reply := waitPublished(t, published)
+	if reply["request_id"] != "ai-1" || reply["plugin"] != "tools.ai" {
+		t.Errorf("reply not addressed to the requester: %v", reply)
+	}
+	result, _ := reply["result"].(map[string]any)
+	if result["model"] != "m1" {
+		t.Errorf("expected client result in reply, got %v", reply)
+	}
+}
+
+func TestSamplingClientError(t *testing.T) {
+	sender, published := publishRecorder()
+	s := startClientSession(t, sender, `{"sampling":{}}`)
+
+	s.events <- samplingEvent(t, map[string]any{"request_id": "ai-2", "params": map[string]any{"maxTokens": 1}})
+	var req clientRequest
+	json.Unmarshal([]byte(s.out.next(t)), &req)
+	s.send(t, `{"jsonrpc":"2.0","id":"`+req.ID+`","error":{"code":-1,"message":"user rejected sampling"}}`)
+
+	reply := waitPublished(t, published)
+	if msg, _ := reply["error"].(string); !strings.Contains(msg, "user rejected sampling") {
+		t.Errorf("expected client error in reply, got %v", reply)
+	}
+}
+
+func TestSamplingRequiresCapability(t *testing.T) {
+	sender, published := publishRecorder()
+	s := startClientSession(t, sender, `{}`)
+
+	s.events <- samplingEvent(t, map[string]any{"request_id": "ai-3", "params": map[string]any{"maxTokens": 1}})
+	reply := waitPublished(t, published)
+	if reply["error"] != errSamplingUnsupported.Error() {
+		t.Errorf("expected unsupported error, got %v", reply)
+	}
+}
+
+func TestSamplingTimeoutCancelsClientRequest(t *testing.T) {
+	sender, published := publishRecorder()
+	s := startClientSession(t, sender, `{"sampling":{}}`, WithClientRequestTimeout(50*time.Millisecond))
+
+	s.events <- samplingEvent(t, map[string]any{"request_id": "ai-4", "params": map[string]any{"maxTokens": 1}})
+	var req clientRequest
+	json.Unmarshal([]byte(s.out.next(t)), &req)
+
+	var notif struct {
+		Method string          `json:"method"`
+		Params cancelledParams `json:"params"`
+	}
+	if err := json.Unmarshal([]byte(s.out.next(t)), &notif); err != nil {
+		t.Fatalf("parse notification: %v", err)
+	}
+	if notif.Method != "notifications/cancelled" || notif.Params.RequestID != req.ID {
+		t.Errorf("expected cancellation of %s, got %+v", req.ID, notif)
+	}
+	reply := waitPublished(t, published)
+	if msg, _ := reply["error"].(string); !strings.Contains(msg, "deadline exceeded") {
+		t.Errorf("expected timeout error, got %v", reply)
+	}
+
+	// A late response is dropped rather than handled as a request.
+	s.send(t, `{"jsonrpc":"2.0","id":"`+req.ID+`","result":{}}`)
+	s.send(t, `{"jsonrpc":"2.0","id":1,"method":"ping"}`)
+	if resp := parseJSONRPCResponse(t, s.out.next(t)); resp.ID != float64(1) || resp.Error != nil {
+		t.Errorf("expected ping response, got %+v", resp)
+	}
+}
+
+func TestSamplingIgnoresOtherSessions(t *testing.T) {
+	sender, published := publishRecorder()
+	s := startClientSession(t, sender, `{"sampling":{}}`)
+
+	s.events <- samplingEvent(t, map[string]any{"request_id": "ai-5", "session_id": "other", "params": map[string]any{"maxTokens": 1}})
+	s.events <- samplingEvent(t, map[string]any{"request_id": "ai-6", "session_id": s.tr.sessionID, "params": map[string]any{"maxTokens": 1}})
+
+	// Only the request naming this session reaches the client.
+	var req clientRequest
+	json.Unmarshal([]byte(s.out.next(t)), &req)
+	s.send(t, `{"jsonrpc":"2.0","id":"`+req.ID+`","result":{"role":"assistant","content":{"type":"text","text":"ok"},"model":"m1"}}`)
+	if reply := waitPublished(t, published); reply["request_id"] != "ai-6" {
+		t.Errorf("expected a reply to ai-6, got %v", reply)
+	}
+	select {
+	case p := <-published:
+		t.Errorf("unexpected reply for another session: %v", p.GetPayload().AsMap())
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestClientRequestFailsAfterClose(t *testing.T) {
+	tr := NewStdioTransport(&mockSender{}, nil, newLineWriter(), WithClientRequestTimeout(0))
+	tr.failClientRequests(errSessionClosed)
+
+	// Without a timeout, a request nothing answers would wait forever.
+	done := make(chan error, 1)
+	go func() {
+		_, err := tr.requestClient(context.Background(), "roots/list", nil)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if !errors.Is(err, errSessionClosed) {
+			t.Errorf("expected errSessionClosed, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("requestClient blocked after the session closed")
+	}
+	if tr.background(func() {}) {
+		t.Error("expected no background work to start after the session closed")
+	}
+}
+
+func TestRunWaitsForBridgedRequests(t *testing.T) {
+	sender, published := publishRecorder()
+	pr, pw := io.Pipe()
+	out := newLineWriter()
+	tr := NewStdioTransport(sender, pr, out, WithClientRequestTimeout(0))
+	done := make(chan error, 1)
+	go func() { done <- tr.Run(context.Background()) }()
+
+	pw.Write([]byte(`{"jsonrpc":"2.0","id":0,"method":"initialize","params":{"protocolVersion":"2025-06-18","capabilities":{"sampling":{}}}}` + "\n"))
+	out.next(t)
+	pw.Write([]byte(`{"jsonrpc":"2.0","method":"notifications/initialized"}` + "\n"))
+	pw.Write([]byte(`{"jsonrpc":"2.0","id":1,"method":"ping"}` + "\n"))
+	out.next(t)
+
+	tr.forwardClientRequest(samplingEvent(t, map[string]any{"request_id": "ai-7", "params": map[string]any{"maxTokens": 1}}))
+	out.next(t) // sampling/createMessage reached the client
+
+	// The client goes away without answering.
+	pw.Close()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return")
+	}
+	select {
+	case p := <-published:
+		if msg, _ := p.GetPayload().AsMap()["error"].(string); !strings.Contains(msg, errSessionClosed.Error()) {
+			t.Errorf("expected a session closed reply, got %v", p.GetPayload().AsMap())
+		}
+	default:
+		t.Error("expected the bridged request to be answered before Run returned")
+	}
+}
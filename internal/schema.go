@@ -0,0 +1,138 @@
+package internal
+
+import (
+	"fmt"
+	"maps"
+	"math"
+	"reflect"
+	"slices"
+	"unicode/utf8"
+)
+
+// validateSchema checks a JSON value, as decoded into Go by StructToMap or
+// encoding/json, against a JSON Schema. It supports the subset used by tool
+// output schemas and elicitation requests: type, enum, const, properties,
+// required, additionalProperties, items, minLength, maxLength, minimum and
+// maximum. Other keywords are ignored.
+func validateSchema(schema map[string]any, v any) error {
+	return validateAt(schema, v, "$")
+}
+
+func validateAt(schema map[string]any, v any, path string) error {
+	if typ, ok := schema["type"]; ok && !matchesType(typ, v) {
+		return fmt.Errorf("%s: expected %v, got %s", path, typ, jsonType(v))
+	}
+	if enum, ok := schema["enum"].([]any); ok {
+		if !slices.ContainsFunc(enum, func(e any) bool { return reflect.DeepEqual(e, v) }) {
+			return fmt.Errorf("%s: value not in enum", path)
+		}
+	}
+	if c, ok := schema["const"]; ok && !reflect.DeepEqual(c, v) {
+		return fmt.Errorf("%s: expected constant %v", path, c)
+	}
+
+	switch val := v.(type) {
+	case map[string]any:
+		required, _ := schema["required"].([]any)
+		for _, r := range required {
+			name, _ := r.(string)
+			if _, ok := val[name]; !ok {
+				return fmt.Errorf("%s: missing required property %q", path, name)
+			}
+		}
+		props, _ := schema["properties"].(map[string]any)
+		for _, k := range slices.Sorted(maps.Keys(val)) {
+			sub := path + "." + k
+			if ps, ok := props[k].(map[string]any); ok {
+				if err := validateAt(ps, val[k], sub); err != nil {
+					return err
+				}
+				continue
+			}
+			switch ap := schema["additionalProperties"].(type) {
+			case bool:
+				if !ap {
+					return fmt.Errorf("%s: additional property not allowed", sub)
+				}
+			case map[string]any:
+				if err := validateAt(ap, val[k], sub); err != nil {
+					return err
+				}
+			}
+		}
+	case string:
+		n := float64(utf8.RuneCountInString(val))
+		if min, ok := schema["minLength"].(float64); ok && n < min {
+			return fmt.Errorf("%s: shorter than %v characters", path, min)
+		}
+		if max, ok := schema["maxLength"].(float64); ok && n > max {
+			return fmt.Errorf("%s: longer than %v characters", path, max)
+		}
+	case float64:
+		if min, ok := schema["minimum"].(float64); ok && val < min {
+			return fmt.Errorf("%s: less than minimum %v", path, min)
+		}
+		if max, ok := schema["maximum"].(float64); ok && val > max {
+			return fmt.Errorf("%s: greater than maximum %v", path, max)
+		}
+	case []any:
+		if items, ok := schema["items"].(map[string]any); ok {
+			for i, item := range val {
+				if err := validateAt(items, item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// matchesType reports whether v has the schema type typ, which is a type name
+// or a list of them.
+func matchesType(typ any, v any) bool {
+	switch tt := typ.(type) {
+	case string:
+		return matchesTypeName(tt, v)
+	case []any:
+		return slices.ContainsFunc(tt, func(t any) bool {
+			name, _ := t.(string)
+			return matchesTypeName(name, v)
+		})
+	default:
+		return true
+	}
+}
+
+func matchesTypeName(name string, v any) bool {
+	got := jsonType(v)
+	switch name {
+	case "number":
+		return got == "number" || got == "integer"
+	default:
+		return got == name
+	}
+}
+
+// jsonType returns the JSON Schema type name of a decoded JSON value. Whole
+// numbers are reported as "integer".
+func jsonType(v any) string {
+	switch n := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		if n == math.Trunc(n) && !math.IsInf(n, 0) {
+			return "integer"
+		}
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
@@ -0,0 +1,61 @@
+package internal
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestValidateSchema(t *testing.T) {
+	schema := map[string]any{}
+	json.Unmarshal([]byte(`{
+		"type": "object",
+		"required": ["id", "tags"],
+		"additionalProperties": false,
+		"properties": {
+			"id":     {"type": "string"},
+			"count":  {"type": "integer", "minimum": 0, "maximum": 10},
+			"name":   {"type": "string", "minLength": 2, "maxLength": 4},
+			"score":  {"type": ["number", "null"]},
+			"status": {"enum": ["open", "closed"]},
+			"tags":   {"type": "array", "items": {"type": "string"}}
+		}
+	}`), &schema)
+
+	tests := []struct {
+		name    string
+		value   string
+		wantErr string // empty for valid
+	}{
+		{"valid", `{"id":"a","count":2,"score":null,"status":"open","tags":["x"]}`, ""},
+		{"number accepts integer", `{"id":"a","score":3,"tags":[]}`, ""},
+		{"not an object", `"a"`, "$: expected object, got string"},
+		{"missing required", `{"id":"a"}`, `$: missing required property "tags"`},
+		{"wrong type", `{"id":1,"tags":[]}`, "$.id: expected string, got integer"},
+		{"not an integer", `{"id":"a","count":1.5,"tags":[]}`, "$.count: expected integer, got number"},
+		{"enum", `{"id":"a","status":"pending","tags":[]}`, "$.status: value not in enum"},
+		{"items", `{"id":"a","tags":["x",2]}`, "$.tags[1]: expected string, got integer"},
+		{"additional property", `{"id":"a","tags":[],"extra":true}`, "$.extra: additional property not allowed"},
+		{"null", `null`, "$: expected object, got null"},
+		{"minimum", `{"id":"a","count":-1,"tags":[]}`, "$.count: less than minimum 0"},
+		{"maximum", `{"id":"a","count":11,"tags":[]}`, "$.count: greater than maximum 10"},
+		{"minLength", `{"id":"a","name":"x","tags":[]}`, "$.name: shorter than 2 characters"},
+		{"maxLength counts characters", `{"id":"a","name":"héllo","tags":[]}`, "$.name: longer than 4 characters"},
+		{"length in range", `{"id":"a","name":"héll","tags":[]}`, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var v any
+			if err := json.Unmarshal([]byte(tt.value), &v); err != nil {
+				t.Fatalf("bad test value: %v", err)
+			}
+			err := validateSchema(schema, v)
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("unexpected error: %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Errorf("error: got %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
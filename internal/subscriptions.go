@@ -0,0 +1,74 @@
+package internal
+
+import (
+	pluginv1 "github.com/orchestra-mcp/gen-go/orchestra/plugin/v1"
+)
+
+// Storage event topics published by storage plugins when a document changes.
+// The payload carries the storage path and, for writes, whether the document
+// was newly created:
+//
+//	{"path": "features/FEAT-ABC.md", "created": true}
+const (
+	storageWriteTopic  = "storage.write"
+	storageDeleteTopic = "storage.delete"
+)
+
+// resourceUpdatedParams is the JSON shape of a notifications/resources/updated
+// message.
+type resourceUpdatedParams struct {
+	URI string `json:"uri"`
+}
+
+// subscribe records a client subscription to a resource URI.
+func (t *StdioTransport) subscribe(uri string) {
+	t.subsMu.Lock()
+	defer t.subsMu.Unlock()
+	if t.subscriptions == nil {
+		t.subscriptions = make(map[string]struct{})
+	}
+	t.subscriptions[uri] = struct{}{}
+}
+
+// unsubscribe removes a client subscription to a resource URI.
+func (t *StdioTransport) unsubscribe(uri string) {
+	t.subsMu.Lock()
+	defer t.subsMu.Unlock()
+	delete(t.subscriptions, uri)
+}
+
+// isSubscribed reports whether the client subscribed to a resource URI.
+func (t *StdioTransport) isSubscribed(uri string) bool {
+	t.subsMu.Lock()
+	defer t.subsMu.Unlock()
+	_, ok := t.subscriptions[uri]
+	return ok
+}
+
+// handleStorageEvent turns a storage write or delete event into resource
+// notifications: notifications/resources/updated when the client subscribed
+// to the affected resource, and notifications/resources/list_changed when a
+// resource was created or removed. It also drops the affected entry from
+// the resource cache, unless an HTTPTransport does so for all its sessions.
+// Paths outside the resource namespaces are ignored.
+func (t *StdioTransport) handleStorageEvent(ev *pluginv1.EventDelivery) error {
+	fields := ev.GetPayload().GetFields()
+	if !t.sharedCache {
+		t.resourceCache.invalidateStorageEvent(ev.GetTopic(), ev.GetPayload())
+	}
+	uri, _, ok := t.resourceURIForPath(fields["path"].GetStringValue())
+	if !ok {
+		return nil
+	}
+
+	if t.isSubscribed(uri) {
+		if err := t.writeNotification("notifications/resources/updated", resourceUpdatedParams{URI: uri}); err != nil {
+			return err
+		}
+	}
+
+	if ev.GetTopic() == storageDeleteTopic || fields["created"].GetBoolValue() {
+		return t.writeNotification("notifications/resources/list_changed", nil)
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/orchestra-mcp/sdk-go/protocol"
+)
+
+// codeRequestTimeout is the JSON-RPC error code returned when a request
+// exceeds its timeout, distinguishing it from orchestrator failures
+// (InternalError).
+const codeRequestTimeout = -32001
+
+// RequestTimeouts bounds how long a request may take. A tools/call uses its
+// PerTool entry if present, any other request its PerMethod entry, and
+// otherwise Default. A zero duration means no timeout.
+//
+// Clients may send a hint in the request's "_meta.timeoutMs". A hint shorter
+// than the configured timeout always applies, even if it exceeds Max; a
+// longer one applies up to Max, and is ignored when Max is zero.
+type RequestTimeouts struct {
+	Default   time.Duration
+	PerMethod map[string]time.Duration
+	PerTool   map[string]time.Duration
+	Max       time.Duration
+}
+
+// timeoutError is the cancellation cause of a request that ran out of time.
+type timeoutError struct {
+	method  string
+	timeout time.Duration
+}
+
+func (e *timeoutError) Error() string {
+	return fmt.Sprintf("%s timed out after %s", e.method, e.timeout)
+}
+
+// timeoutParams holds the params fields that select a request's timeout.
+type timeoutParams struct {
+	Name string       `json:"name"`
+	Meta *requestMeta `json:"_meta"`
+}
+
+// WithRequestTimeouts sets per-request timeouts.
+func WithRequestTimeouts(timeouts RequestTimeouts) func(*StdioTransport) {
+	return func(t *StdioTransport) {
+		t.timeouts = &timeouts
+	}
+}
+
+// timeoutFor returns the timeout that applies to req, or 0 for none.
+func (rt *RequestTimeouts) timeoutFor(req *protocol.JSONRPCRequest) time.Duration {
+	var params timeoutParams
+	if req.Params != nil {
+		// Malformed params are reported by the method handler.
+		json.Unmarshal(req.Params, &params)
+	}
+
+	d := rt.Default
+	if m, ok := rt.PerMethod[req.Method]; ok {
+		d = m
+	}
+	if req.Method == "tools/call" {
+		if tool, ok := rt.PerTool[params.Name]; ok {
+			d = tool
+		}
+	}
+
+	if params.Meta == nil || params.Meta.TimeoutMs <= 0 {
+		return d
+	}
+	hint := time.Duration(params.Meta.TimeoutMs) * time.Millisecond
+	switch {
+	case d == 0 || hint <= d:
+		// Shortening the timeout is always allowed, even below Max.
+		return hint
+	case rt.Max > 0:
+		// Lengthening is capped at Max, which never shortens the configured
+		// timeout.
+		return min(hint, max(d, rt.Max))
+	default:
+		return d
+	}
+}
+
+// withTimeout derives the context a request runs under from its configured
+// timeout. The returned stop func must be called when the request completes.
+func (t *StdioTransport) withTimeout(ctx context.Context, req *protocol.JSONRPCRequest) (context.Context, context.CancelFunc) {
+	if t.timeouts == nil {
+		return ctx, func() {}
+	}
+	d := t.timeouts.timeoutFor(req)
+	if d <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeoutCause(ctx, d, &timeoutError{method: req.Method, timeout: d})
+}
+
+// timedOut rewrites the error response of a request whose context timed out
+// with codeRequestTimeout, and cancels a timed-out tools/call in the
+// orchestrator. Successful responses are returned unchanged.
+func (t *StdioTransport) timedOut(ctx context.Context, req *protocol.JSONRPCRequest, resp *protocol.JSONRPCResponse) *protocol.JSONRPCResponse {
+	var te *timeoutError
+	if resp == nil || resp.Error == nil || !errors.As(context.Cause(ctx), &te) {
+		return resp
+	}
+	if req.Method == "tools/call" {
+		t.propagateCancel(ctx, req.ID)
+	}
+	return &protocol.JSONRPCResponse{
+		JSONRPC: "2.0",
+		ID:      req.ID,
+		Error: &protocol.JSONRPCError{
+			Code:    codeRequestTimeout,
+			Message: te.Error(),
+		},
+	}
+}
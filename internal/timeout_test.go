@@ -0,0 +1,97 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	pluginv1 "github.com/orchestra-mcp/gen-go/orchestra/plugin/v1"
+	"github.com/orchestra-mcp/sdk-go/protocol"
+)
+
+func TestRequestTimeoutsSelection(t *testing.T) {
+	rt := &RequestTimeouts{
+		Default:   10 * time.Second,
+		PerMethod: map[string]time.Duration{"tools/call": 30 * time.Second},
+		PerTool:   map[string]time.Duration{"send_message": 10 * time.Minute},
+	}
+	capped := *rt
+	capped.Max = time.Minute
+
+	tests := []struct {
+		name   string
+		rt     *RequestTimeouts
+		method string
+		params string
+		want   time.Duration
+	}{
+		{"default", rt, "prompts/list", ``, 10 * time.Second},
+		{"per method", rt, "tools/call", `{"name":"echo"}`, 30 * time.Second},
+		{"per tool", rt, "tools/call", `{"name":"send_message"}`, 10 * time.Minute},
+		{"shorter hint", rt, "tools/call", `{"name":"echo","_meta":{"timeoutMs":500}}`, 500 * time.Millisecond},
+		{"longer hint ignored", rt, "tools/call", `{"name":"echo","_meta":{"timeoutMs":120000}}`, 30 * time.Second},
+		{"longer hint capped", &capped, "tools/call", `{"name":"echo","_meta":{"timeoutMs":120000}}`, time.Minute},
+		{"longer hint within max", &capped, "tools/call", `{"name":"echo","_meta":{"timeoutMs":45000}}`, 45 * time.Second},
+		{"shorter hint above max", &RequestTimeouts{Default: 5 * time.Minute, Max: time.Minute}, "prompts/list", `{"_meta":{"timeoutMs":120000}}`, 2 * time.Minute},
+		{"longer hint with max below default", &RequestTimeouts{Default: 5 * time.Minute, Max: time.Minute}, "prompts/list", `{"_meta":{"timeoutMs":600000}}`, 5 * time.Minute},
+		{"unlimited", &RequestTimeouts{}, "tools/call", `{"name":"echo"}`, 0},
+		{"hint when unlimited", &RequestTimeouts{}, "tools/call", `{"name":"echo","_meta":{"timeoutMs":2000}}`, 2 * time.Second},
+		{"hint above max when unlimited", &RequestTimeouts{Max: time.Second}, "tools/call", `{"name":"echo","_meta":{"timeoutMs":2000}}`, 2 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &protocol.JSONRPCRequest{JSONRPC: "2.0", ID: 1, Method: tt.method}
+			if tt.params != "" {
+				req.Params = json.RawMessage(tt.params)
+			}
+			if got := tt.rt.timeoutFor(req); got != tt.want {
+				t.Errorf("timeoutFor: got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestToolsCallTimeout(t *testing.T) {
+	var mu sync.Mutex
+	var cancelled []string
+	hung := make(chan struct{})
+	defer close(hung)
+	sender := &mockSender{
+		sendFunc: func(ctx context.Context, req *pluginv1.PluginRequest) (*pluginv1.PluginResponse, error) {
+			if sc := req.GetStreamCancel(); sc != nil {
+				mu.Lock()
+				cancelled = append(cancelled, sc.StreamId)
+				mu.Unlock()
+				return &pluginv1.PluginResponse{}, nil
+			}
+			// A hung plugin that ignores cancellation.
+			<-hung
+			return nil, nil
+		},
+	}
+	tr := newReadyTransport(t, sender, nil, nil, WithRequestTimeouts(RequestTimeouts{
+		PerTool: map[string]time.Duration{"hang": 20 * time.Millisecond},
+	}))
+
+	resp := tr.dispatch(context.Background(), &protocol.JSONRPCRequest{
+		JSONRPC: "2.0",
+		ID:      4,
+		Method:  "tools/call",
+		Params:  json.RawMessage(`{"name":"hang"}`),
+	})
+	if resp.Error == nil || resp.Error.Code != codeRequestTimeout {
+		t.Fatalf("expected timeout error, got %+v", resp.Error)
+	}
+	if !strings.Contains(resp.Error.Message, "timed out after 20ms") {
+		t.Errorf("unexpected message: %q", resp.Error.Message)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(cancelled) != 1 || cancelled[0] != tr.toolCallRequestID(4) {
+		t.Errorf("expected StreamCancel for the timed-out call, got %v", cancelled)
+	}
+}
@@ -0,0 +1,219 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"path"
+	"slices"
+)
+
+// toolExtensionKey is the input schema property under which plugins declare
+// MCP tool fields that pluginv1.ToolDefinition has no field for:
+//
+//	{"type": "object", "properties": {...},
+//	 "x-mcp": {
+//	   "title": "Delete Project",
+//	   "annotations": {"destructiveHint": true},
+//	   "outputSchema": {"type": "object", ...}
+//	 }}
+//
+// The extension is removed from the inputSchema sent to clients.
+const toolExtensionKey = "x-mcp"
+
+// ToolDefinition is an MCP tool definition. It mirrors
+// protocol.MCPToolDefinition with the fields the SDK does not model yet.
+type ToolDefinition struct {
+	Name         string           `json:"name"`
+	Title        string           `json:"title,omitempty"`
+	Description  string           `json:"description,omitempty"`
+	InputSchema  any              `json:"inputSchema"`
+	OutputSchema map[string]any   `json:"outputSchema,omitempty"`
+	Annotations  *ToolAnnotations `json:"annotations,omitempty"`
+}
+
+// ToolAnnotations are MCP hints about a tool's behaviour that clients use to
+// decide, for example, whether to ask for confirmation. Unset hints are
+// omitted so clients apply the spec defaults.
+type ToolAnnotations struct {
+	Title           string `json:"title,omitempty"`
+	ReadOnlyHint    *bool  `json:"readOnlyHint,omitempty"`
+	DestructiveHint *bool  `json:"destructiveHint,omitempty"`
+	IdempotentHint  *bool  `json:"idempotentHint,omitempty"`
+	OpenWorldHint   *bool  `json:"openWorldHint,omitempty"`
+}
+
+// ToolOverride replaces the title and annotations of matching tools. Only the
+// fields that are set replace the plugin's values.
+type ToolOverride struct {
+	Title       string           `json:"title,omitempty"`
+	Annotations *ToolAnnotations `json:"annotations,omitempty"`
+}
+
+// toolExtension holds the fields declared under toolExtensionKey.
+type toolExtension struct {
+	Title        string           `json:"title"`
+	Annotations  *ToolAnnotations `json:"annotations"`
+	OutputSchema map[string]any   `json:"outputSchema"`
+}
+
+// takeToolExtension removes the extension object from a converted input
+// schema and returns its fields. A malformed extension is ignored.
+func takeToolExtension(inputSchema map[string]any) toolExtension {
+	raw, ok := inputSchema[toolExtensionKey]
+	if !ok {
+		return toolExtension{}
+	}
+	delete(inputSchema, toolExtensionKey)
+
+	var ext toolExtension
+	data, err := json.Marshal(raw)
+	if err == nil {
+		err = json.Unmarshal(data, &ext)
+	}
+	if err != nil {
+		slog.Debug("ignoring malformed tool extension", "error", err)
+		return toolExtension{}
+	}
+	return ext
+}
+
+// WithToolOverrides sets title and annotation overrides for tools. Keys are
+// tool names or path.Match patterns such as "delete_*"; an exact name takes
+// precedence over patterns, and patterns are tried in lexical order.
+func WithToolOverrides(overrides map[string]ToolOverride) func(*StdioTransport) {
+	return func(t *StdioTransport) {
+		t.toolOverrides = overrides
+	}
+}
+
+// LoadToolOverrides reads tool overrides from a JSON file mapping tool names
+// or patterns to overrides:
+//
+//	{"list_*": {"annotations": {"readOnlyHint": true}},
+//	 "delete_project": {"title": "Delete Project", "annotations": {"destructiveHint": true}}}
+func LoadToolOverrides(file string) (map[string]ToolOverride, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("read tool overrides: %w", err)
+	}
+	var overrides map[string]ToolOverride
+	if err := json.Unmarshal(data, &overrides); err != nil {
+		return nil, fmt.Errorf("parse tool overrides %s: %w", file, err)
+	}
+	for pattern := range overrides {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("tool overrides %s: bad pattern %q: %w", file, pattern, err)
+		}
+	}
+	return overrides, nil
+}
+
+// applyToolOverride applies the override matching the tool's name, if any,
+// and mirrors the title into the annotations for clients that only read
+// annotations.title.
+func (t *StdioTransport) applyToolOverride(def *ToolDefinition) {
+	if o, ok := t.toolOverride(def.Name); ok {
+		if o.Title != "" {
+			def.Title = o.Title
+		}
+		if o.Annotations != nil {
+			def.Annotations = mergeAnnotations(def.Annotations, o.Annotations)
+		}
+	}
+	if def.Title != "" && (def.Annotations == nil || def.Annotations.Title == "") {
+		def.Annotations = mergeAnnotations(def.Annotations, &ToolAnnotations{Title: def.Title})
+	}
+}
+
+// toolOverride finds the override for a tool name.
+func (t *StdioTransport) toolOverride(name string) (ToolOverride, bool) {
+	if o, ok := t.toolOverrides[name]; ok {
+		return o, true
+	}
+	for _, pattern := range slices.Sorted(maps.Keys(t.toolOverrides)) {
+		if ok, _ := path.Match(pattern, name); ok {
+			return t.toolOverrides[pattern], true
+		}
+	}
+	return ToolOverride{}, false
+}
+
+// mergeAnnotations returns base with the fields set in override replacing its
+// own. base is not modified.
+func mergeAnnotations(base, override *ToolAnnotations) *ToolAnnotations {
+	var out ToolAnnotations
+	if base != nil {
+		out = *base
+	}
+	if override.Title != "" {
+		out.Title = override.Title
+	}
+	if override.ReadOnlyHint != nil {
+		out.ReadOnlyHint = override.ReadOnlyHint
+	}
+	if override.DestructiveHint != nil {
+		out.DestructiveHint = override.DestructiveHint
+	}
+	if override.IdempotentHint != nil {
+		out.IdempotentHint = override.IdempotentHint
+	}
+	if override.OpenWorldHint != nil {
+		out.OpenWorldHint = override.OpenWorldHint
+	}
+	return &out
+}
+
+// WithOutputValidation enables validation of tool results against the
+// outputSchema declared by the tool. A result that does not match is returned
+// to the client as a tool error. Schemas are learned from tools/list.
+func WithOutputValidation(enabled bool) func(*StdioTransport) {
+	return func(t *StdioTransport) {
+		t.validateOutput = enabled
+	}
+}
+
+// rememberOutputSchemas records the output schemas of the listed tools for
+// result validation, replacing those from any earlier listing.
+func (t *StdioTransport) rememberOutputSchemas(defs []ToolDefinition) {
+	schemas := make(map[string]map[string]any)
+	for _, d := range defs {
+		if d.OutputSchema != nil {
+			schemas[d.Name] = d.OutputSchema
+		}
+	}
+	t.schemasMu.Lock()
+	t.outputSchemas = schemas
+	t.schemasMu.Unlock()
+}
+
+// checkOutput validates a successful tool result against the tool's output
+// schema, if validation is enabled and the schema is known. A mismatch turns
+// the result into a tool error describing it.
+func (t *StdioTransport) checkOutput(tool string, result ToolResult) ToolResult {
+	if !t.validateOutput || result.IsError {
+		return result
+	}
+	t.schemasMu.Lock()
+	schema := t.outputSchemas[tool]
+	t.schemasMu.Unlock()
+	if schema == nil {
+		return result
+	}
+
+	var structured any
+	if result.StructuredContent != nil {
+		structured = result.StructuredContent
+	}
+	if err := validateSchema(schema, structured); err != nil {
+		return ToolResult{
+			Content: []ContentBlock{
+				{Type: "text", Text: fmt.Sprintf("tool %s returned output that does not match its outputSchema: %v", tool, err)},
+			},
+			IsError: true,
+		}
+	}
+	return result
+}
@@ -0,0 +1,268 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	pluginv1 "github.com/orchestra-mcp/gen-go/orchestra/plugin/v1"
+	"github.com/orchestra-mcp/sdk-go/protocol"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// statsToolSender lists a "stats" tool declaring an output schema and answers
+// calls to it with the given result.
+func statsToolSender(t *testing.T, result map[string]any) *mockSender {
+	t.Helper()
+	schema, err := structpb.NewStruct(map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"project": map[string]any{"type": "string"},
+		},
+		toolExtensionKey: map[string]any{
+			"outputSchema": map[string]any{
+				"type":     "object",
+				"required": []any{"open", "closed"},
+				"properties": map[string]any{
+					"open":   map[string]any{"type": "integer"},
+					"closed": map[string]any{"type": "integer"},
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewStruct: %v", err)
+	}
+	res, err := structpb.NewStruct(result)
+	if err != nil {
+		t.Fatalf("NewStruct: %v", err)
+	}
+	return &mockSender{
+		sendFunc: func(ctx context.Context, req *pluginv1.PluginRequest) (*pluginv1.PluginResponse, error) {
+			if req.GetListTools() != nil {
+				return &pluginv1.PluginResponse{
+					Response: &pluginv1.PluginResponse_ListTools{
+						ListTools: &pluginv1.ListToolsResponse{
+							Tools: []*pluginv1.ToolDefinition{{Name: "stats", InputSchema: schema}},
+						},
+					},
+				}, nil
+			}
+			return &pluginv1.PluginResponse{
+				Response: &pluginv1.PluginResponse_ToolCall{
+					ToolCall: &pluginv1.ToolResponse{Success: true, Result: res},
+				},
+			}, nil
+		},
+	}
+}
+
+// runToolsListThenCall lists tools and then calls "stats", returning both
+// responses.
+func runToolsListThenCall(t *testing.T, sender Sender, opts ...func(*StdioTransport)) (list, call protocol.JSONRPCResponse) {
+	t.Helper()
+	tr := newReadyTransport(t, sender, nil, nil, opts...)
+	ctx := context.Background()
+	l := tr.dispatch(ctx, &protocol.JSONRPCRequest{JSONRPC: "2.0", ID: 1, Method: "tools/list"})
+	c := tr.dispatch(ctx, &protocol.JSONRPCRequest{
+		JSONRPC: "2.0", ID: 2, Method: "tools/call", Params: json.RawMessage(`{"name":"stats"}`),
+	})
+	return roundTrip(t, l), roundTrip(t, c)
+}
+
+// roundTrip encodes a response and decodes it again, as a client would see it.
+func roundTrip(t *testing.T, resp *protocol.JSONRPCResponse) protocol.JSONRPCResponse {
+	t.Helper()
+	raw, err := json.Marshal(resp)
+	if err != nil {
+		t.Fatalf("marshal response: %v", err)
+	}
+	return parseJSONRPCResponse(t, string(raw))
+}
+
+func TestToolsListOutputSchema(t *testing.T) {
+	list, _ := runToolsListThenCall(t, statsToolSender(t, map[string]any{"open": 1, "closed": 2}))
+
+	raw, _ := json.Marshal(list.Result)
+	var result struct {
+		Tools []map[string]any `json:"tools"`
+	}
+	json.Unmarshal(raw, &result)
+	if len(result.Tools) != 1 {
+		t.Fatalf("expected 1 tool, got %d", len(result.Tools))
+	}
+	tool := result.Tools[0]
+	if _, ok := tool["inputSchema"].(map[string]any)[toolExtensionKey]; ok {
+		t.Error("expected the extension to be removed from inputSchema")
+	}
+	out, ok := tool["outputSchema"].(map[string]any)
+	if !ok || out["type"] != "object" {
+		t.Errorf("expected outputSchema to be forwarded, got %v", tool["outputSchema"])
+	}
+}
+
+func TestToolsCallStructuredContent(t *testing.T) {
+	_, call := runToolsListThenCall(t, statsToolSender(t, map[string]any{"open": 3, "closed": 4}))
+
+	raw, _ := json.Marshal(call.Result)
+	var result ToolResult
+	if err := json.Unmarshal(raw, &result); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if result.StructuredContent["open"] != float64(3) || result.StructuredContent["closed"] != float64(4) {
+		t.Errorf("structuredContent: got %v", result.StructuredContent)
+	}
+	if len(result.Content) != 1 || !strings.Contains(result.Content[0].Text, `"open":3`) {
+		t.Errorf("expected JSON text rendering for compatibility, got %+v", result.Content)
+	}
+}
+
+func TestToolsCallOutputValidation(t *testing.T) {
+	bad := map[string]any{"open": "three"}
+
+	// Without validation the result is forwarded as is.
+	_, call := runToolsListThenCall(t, statsToolSender(t, bad))
+	if raw, _ := json.Marshal(call.Result); strings.Contains(string(raw), `"isError":true`) {
+		t.Errorf("unexpected error without validation: %s", raw)
+	}
+
+	_, call = runToolsListThenCall(t, statsToolSender(t, bad), WithOutputValidation(true))
+	raw, _ := json.Marshal(call.Result)
+	var result ToolResult
+	json.Unmarshal(raw, &result)
+	if !result.IsError || !strings.Contains(result.Content[0].Text, "does not match its outputSchema") {
+		t.Errorf("expected validation error, got %s", raw)
+	}
+
+	_, call = runToolsListThenCall(t, statsToolSender(t, map[string]any{"open": 1, "closed": 0}), WithOutputValidation(true))
+	if raw, _ := json.Marshal(call.Result); strings.Contains(string(raw), `"isError":true`) {
+		t.Errorf("valid output rejected: %s", raw)
+	}
+}
+
+// listedTools runs tools/list against the given definitions and returns the
+// tools as decoded JSON objects keyed by name.
+func listedTools(t *testing.T, defs []*pluginv1.ToolDefinition, opts ...func(*StdioTransport)) map[string]map[string]any {
+	t.Helper()
+	sender := &mockSender{
+		sendFunc: func(ctx context.Context, req *pluginv1.PluginRequest) (*pluginv1.PluginResponse, error) {
+			return &pluginv1.PluginResponse{
+				Response: &pluginv1.PluginResponse_ListTools{
+					ListTools: &pluginv1.ListToolsResponse{Tools: defs},
+				},
+			}, nil
+		},
+	}
+	tr := newReadyTransport(t, sender, nil, nil, opts...)
+	resp := roundTrip(t, tr.dispatch(context.Background(), &protocol.JSONRPCRequest{JSONRPC: "2.0", ID: 1, Method: "tools/list"}))
+
+	raw, _ := json.Marshal(resp.Result)
+	var result struct {
+		Tools []map[string]any `json:"tools"`
+	}
+	json.Unmarshal(raw, &result)
+	tools := make(map[string]map[string]any)
+	for _, tool := range result.Tools {
+		tools[tool["name"].(string)] = tool
+	}
+	return tools
+}
+
+// toolWithExtension builds a ToolDefinition whose input schema carries the
+// given extension object.
+func toolWithExtension(t *testing.T, name string, ext map[string]any) *pluginv1.ToolDefinition {
+	t.Helper()
+	fields := map[string]any{"type": "object"}
+	if ext != nil {
+		fields[toolExtensionKey] = ext
+	}
+	schema, err := structpb.NewStruct(fields)
+	if err != nil {
+		t.Fatalf("NewStruct: %v", err)
+	}
+	return &pluginv1.ToolDefinition{Name: name, InputSchema: schema}
+}
+
+func TestToolsListAnnotationsFromExtension(t *testing.T) {
+	tools := listedTools(t, []*pluginv1.ToolDefinition{
+		toolWithExtension(t, "delete_project", map[string]any{
+			"title":       "Delete Project",
+			"annotations": map[string]any{"destructiveHint": true, "idempotentHint": false},
+		}),
+		toolWithExtension(t, "echo", nil),
+	})
+
+	del := tools["delete_project"]
+	if del["title"] != "Delete Project" {
+		t.Errorf("title: got %v", del["title"])
+	}
+	ann, _ := del["annotations"].(map[string]any)
+	if ann["destructiveHint"] != true || ann["idempotentHint"] != false || ann["title"] != "Delete Project" {
+		t.Errorf("annotations: got %v", ann)
+	}
+	if _, ok := ann["readOnlyHint"]; ok {
+		t.Error("unset hints should be omitted")
+	}
+	if _, ok := tools["echo"]["annotations"]; ok {
+		t.Error("expected no annotations for a tool without any")
+	}
+}
+
+func TestToolsListAnnotationOverrides(t *testing.T) {
+	yes, no := true, false
+	overrides := map[string]ToolOverride{
+		"list_*":      {Annotations: &ToolAnnotations{ReadOnlyHint: &yes}},
+		"list_secret": {Title: "List Secrets", Annotations: &ToolAnnotations{OpenWorldHint: &no}},
+		"delete_*":    {Annotations: &ToolAnnotations{DestructiveHint: &yes}},
+	}
+	tools := listedTools(t, []*pluginv1.ToolDefinition{
+		toolWithExtension(t, "list_projects", nil),
+		toolWithExtension(t, "list_secret", nil),
+		toolWithExtension(t, "delete_project", map[string]any{
+			"annotations": map[string]any{"destructiveHint": false, "idempotentHint": true},
+		}),
+	}, WithToolOverrides(overrides))
+
+	if ann, _ := tools["list_projects"]["annotations"].(map[string]any); ann["readOnlyHint"] != true {
+		t.Errorf("list_projects: expected readOnlyHint from pattern, got %v", ann)
+	}
+
+	secret := tools["list_secret"]
+	ann, _ := secret["annotations"].(map[string]any)
+	if secret["title"] != "List Secrets" || ann["openWorldHint"] != false {
+		t.Errorf("list_secret: expected exact override, got %v", secret)
+	}
+	if _, ok := ann["readOnlyHint"]; ok {
+		t.Error("list_secret: exact override should take precedence over patterns")
+	}
+
+	if ann, _ := tools["delete_project"]["annotations"].(map[string]any); ann["destructiveHint"] != true || ann["idempotentHint"] != true {
+		t.Errorf("delete_project: expected override merged over plugin hints, got %v", ann)
+	}
+}
+
+func TestLoadToolOverrides(t *testing.T) {
+	dir := t.TempDir()
+	good := filepath.Join(dir, "overrides.json")
+	os.WriteFile(good, []byte(`{"list_*":{"annotations":{"readOnlyHint":true}},"delete_project":{"title":"Delete Project"}}`), 0o600)
+
+	overrides, err := LoadToolOverrides(good)
+	if err != nil {
+		t.Fatalf("LoadToolOverrides: %v", err)
+	}
+	if rh := overrides["list_*"].Annotations.ReadOnlyHint; rh == nil || !*rh {
+		t.Errorf("list_*: got %+v", overrides["list_*"])
+	}
+	if overrides["delete_project"].Title != "Delete Project" {
+		t.Errorf("delete_project: got %+v", overrides["delete_project"])
+	}
+
+	bad := filepath.Join(dir, "bad.json")
+	os.WriteFile(bad, []byte(`{"list_[":{}}`), 0o600)
+	if _, err := LoadToolOverrides(bad); err == nil {
+		t.Error("expected an error for a malformed pattern")
+	}
+}
@@ -10,46 +10,70 @@ import (
 )
 
 // ToolDefinitionToMCP converts a protobuf ToolDefinition to an MCP-compatible
-// MCPToolDefinition. The InputSchema (a protobuf Struct) is converted to a
-// native Go map so it serializes as a JSON object.
-func ToolDefinitionToMCP(td *pluginv1.ToolDefinition) protocol.MCPToolDefinition {
+// ToolDefinition. The InputSchema (a protobuf Struct) is converted to a
+// native Go map so it serializes as a JSON object. MCP fields declared under
+// the schema's toolExtensionKey, such as title, annotations and outputSchema,
+// are moved out of it.
+func ToolDefinitionToMCP(td *pluginv1.ToolDefinition) ToolDefinition {
 	var inputSchema any
+	var ext toolExtension
 	if td.GetInputSchema() != nil {
-		inputSchema = StructToMap(td.GetInputSchema())
+		m := StructToMap(td.GetInputSchema())
+		ext = takeToolExtension(m)
+		inputSchema = m
 	}
 
-	return protocol.MCPToolDefinition{
-		Name:        td.GetName(),
-		Description: td.GetDescription(),
-		InputSchema: inputSchema,
+	return ToolDefinition{
+		Name:         td.GetName(),
+		Title:        ext.Title,
+		Description:  td.GetDescription(),
+		InputSchema:  inputSchema,
+		OutputSchema: ext.OutputSchema,
+		Annotations:  ext.Annotations,
 	}
 }
 
-// ToolResponseToMCP converts a protobuf ToolResponse to an MCP MCPToolResult.
-// Successful responses extract the "text" field from the result Struct.
+// ToolResponseToMCP converts a protobuf ToolResponse to an MCP ToolResult.
+// Successful responses whose result Struct holds a "content" list of MCP
+// content blocks (see resultContent) are passed through as those blocks;
+// otherwise the "text" field is extracted from the result Struct. The result
+// Struct is also forwarded as structuredContent, minus any "content" list.
 // Failed responses produce an error content block with the error message.
-func ToolResponseToMCP(resp *pluginv1.ToolResponse) protocol.MCPToolResult {
+func ToolResponseToMCP(resp *pluginv1.ToolResponse) ToolResult {
 	if !resp.GetSuccess() {
 		errMsg := resp.GetErrorMessage()
 		if errMsg == "" {
 			errMsg = fmt.Sprintf("tool error: %s", resp.GetErrorCode())
 		}
-		return protocol.MCPToolResult{
-			Content: []protocol.MCPContent{
+		return ToolResult{
+			Content: []ContentBlock{
 				{Type: "text", Text: errMsg},
 			},
 			IsError: true,
 		}
 	}
 
+	structured := StructToMap(resp.GetResult())
+
+	// Plugins returning images, audio or resources use the "content" list.
+	if blocks, ok := resultContent(resp.GetResult()); ok {
+		delete(structured, "content")
+		if len(structured) == 0 {
+			structured = nil
+		}
+		return ToolResult{Content: blocks, StructuredContent: structured}
+	}
+
 	// Extract text from the result struct. Tools typically return
-	// {"text": "..."} in the Result field.
+	// {"text": "..."} in the Result field. The text rendering is kept for
+	// clients that do not read structuredContent.
 	text := extractResultText(resp.GetResult())
 
-	return protocol.MCPToolResult{
-		Content: []protocol.MCPContent{
+	return ToolResult{
+		Content: []ContentBlock{
 			{Type: "text", Text: text},
 		},
+		StructuredContent: structured,
 	}
 }
 
@@ -5,22 +5,25 @@
 package internal
 
 import (
-	"bufio"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	pluginv1 "github.com/orchestra-mcp/gen-go/orchestra/plugin/v1"
 	"github.com/orchestra-mcp/sdk-go/protocol"
 	"google.golang.org/protobuf/encoding/protojson"
 )
 
-// maxScannerBuffer is 10 MB, large enough for big JSON-RPC tool responses.
-const maxScannerBuffer = 10 * 1024 * 1024
+// errRequestCancelled is the cancellation cause recorded on a request context
+// when the client sends notifications/cancelled for it.
+var errRequestCancelled = errors.New("request cancelled by client")
 
 // Sender abstracts the QUIC client so StdioTransport can be tested without a
 // real network connection. In production this is backed by
@@ -38,31 +41,82 @@ type OnDisconnect func(sessionID string)
 // through the orchestrator, and writes JSON-RPC responses to an output writer.
 type StdioTransport struct {
 	sender       Sender
-	reader       *bufio.Scanner
+	reader       *lineFramer
 	writer       io.Writer
-	mu           sync.Mutex // protects writer
+	mu           sync.Mutex // protects writer and logLevel
 	sessionID    string
 	logLevel     protocol.MCPLogLevel // minimum level for log notifications (default: warning)
 	onDisconnect OnDisconnect
 	eventCh      <-chan *pluginv1.EventDelivery
 	serverInfo   protocol.MCPServerInfo // injected via WithServerInfo
+	state        atomic.Int32           // LifecycleState
+
+	protocolVersion string                 // negotiated in initialize
+	clientInfo      protocol.MCPServerInfo // client name and version from initialize
+	clientCaps      clientCapabilities     // capabilities declared by the client
+
+	inflightMu sync.Mutex                         // protects inflight
+	inflight   map[string]context.CancelCauseFunc // in-flight requests keyed by JSON-RPC ID
+
+	progressMu     sync.Mutex     // protects progressTokens
+	progressTokens map[string]any // client progress tokens keyed by orchestrator request ID
+
+	pageSize  int    // max items per list page; 0 disables pagination
+	cursorKey []byte // signs pagination cursors
+
+	namespaces    []ResourceNamespace // storage namespaces exposed as resources
+	resourceCache *resourceCache      // caches resources/read; nil means disabled
+	sharedCache   bool                // storage events reach resourceCache through an HTTPTransport
+
+	lists listCache // cached tools/list and prompts/list results
+
+	subsMu        sync.Mutex          // protects subscriptions
+	subscriptions map[string]struct{} // subscribed resource URIs
+
+	limiter  *callLimiter     // bounds concurrent tools/call; nil means unlimited
+	timeouts *RequestTimeouts // per-request timeouts; nil means none
+
+	maxMessageSize int // maximum incoming message size; 0 means unlimited
+
+	allowedOrigins     []string      // extra browser origins for the HTTP endpoint
+	maxSessions        int           // session limit of the HTTP endpoint
+	sessionIdleTimeout time.Duration // when the HTTP endpoint closes idle sessions
+
+	validateOutput bool                      // validate tool results against outputSchema
+	schemasMu      sync.Mutex                // protects outputSchemas
+	outputSchemas  map[string]map[string]any // output schemas from tools/list, by tool name
+	toolOverrides  map[string]ToolOverride   // title/annotation overrides by tool name or pattern
+
+	clientTimeout time.Duration                // how long to wait for the client to answer
+	clientMu      sync.Mutex                   // protects clientSeq, clientPending and closed
+	clientSeq     int64                        // last ID used for a request to the client
+	clientPending map[string]chan clientResult // requests to the client keyed by JSON-RPC ID
+	closed        bool                         // set once the session ends; see closeSession
+
+	wg sync.WaitGroup // requests and background work that Run waits for
+
+	rootsMu  sync.Mutex // protects roots and rootsGen
+	roots    []Root     // client workspace roots from the latest roots/list
+	rootsGen int        // counts roots/list refreshes so stale answers are ignored
 }
 
 // NewStdioTransport creates a new StdioTransport that reads from in and writes
 // to out. The sender is used to communicate with the orchestrator.
 // The optional onDisconnect callback is invoked when the Run loop exits.
 func NewStdioTransport(sender Sender, in io.Reader, out io.Writer, opts ...func(*StdioTransport)) *StdioTransport {
-	scanner := bufio.NewScanner(in)
-	scanner.Buffer(make([]byte, 0, maxScannerBuffer), maxScannerBuffer)
 	t := &StdioTransport{
-		sender:   sender,
-		reader:   scanner,
-		writer:   out,
-		logLevel: protocol.LogLevelWarning,
+		sender:         sender,
+		writer:         out,
+		logLevel:       protocol.LogLevelWarning,
+		cursorKey:      newCursorKey(),
+		maxMessageSize: defaultMaxMessageSize,
+		clientTimeout:  defaultClientRequestTimeout,
+		namespaces:     defaultResourceNamespaces,
 	}
 	for _, opt := range opts {
 		opt(t)
 	}
+	t.reader = newLineFramer(in, t.maxMessageSize)
 	return t
 }
 
@@ -90,6 +144,24 @@ func WithServerInfo(info protocol.MCPServerInfo) func(*StdioTransport) {
 	}
 }
 
+// WithPageSize sets the maximum number of items returned per page by
+// tools/list, prompts/list and resources/list. Clients fetch further pages with
+// the returned nextCursor. A size of 0 (the default) disables pagination.
+func WithPageSize(n int) func(*StdioTransport) {
+	return func(t *StdioTransport) {
+		t.pageSize = n
+	}
+}
+
+// WithMaxMessageSize sets the largest incoming JSON-RPC message, in bytes. A
+// larger message is answered with an error and skipped without ending the
+// session. A size of 0 allows messages of any size. The default is 10 MB.
+func WithMaxMessageSize(n int) func(*StdioTransport) {
+	return func(t *StdioTransport) {
+		t.maxMessageSize = n
+	}
+}
+
 // Run reads lines from the input until EOF or the context is cancelled. Each
 // line is parsed as a JSON-RPC 2.0 request and dispatched to the appropriate
 // handler. Responses are written as single JSON lines to the output.
@@ -98,67 +170,85 @@ func WithServerInfo(info protocol.MCPServerInfo) func(*StdioTransport) {
 // calls (e.g. send_message with wait=true) don't block subsequent requests
 // (e.g. get_pending_permission polls). The writer is mutex-protected so
 // concurrent response writes are safe. A WaitGroup ensures all in-flight
-// requests complete before Run returns.
+// requests complete before Run returns. A notifications/cancelled message
+// aborts the matching tools/call and suppresses its response. WithCallLimits
+// bounds how many calls run at once and how many may wait. Before returning,
+// Run also waits for the session's background work; see closeSession.
 func (t *StdioTransport) Run(ctx context.Context) error {
-	var wg sync.WaitGroup
 	defer func() {
-		wg.Wait()
+		t.closeSession()
 		if t.onDisconnect != nil && t.sessionID != "" {
 			t.onDisconnect(t.sessionID)
 		}
 	}()
 
 	// Event push goroutine: reads EventDelivery from the channel and writes
-	// JSON-RPC notifications to the output. IDE clients that don't understand
-	// these notifications safely ignore them per the JSON-RPC spec.
+	// JSON-RPC notifications to the output until the client goes away.
 	if t.eventCh != nil {
-		wg.Add(1)
+		t.wg.Add(1)
 		go func() {
-			defer wg.Done()
+			defer t.wg.Done()
 			for ev := range t.eventCh {
-				payloadMap := map[string]any{
-					"topic":      ev.GetTopic(),
-					"event_type": ev.GetEventType(),
-					"source":     ev.GetSourcePlugin(),
-				}
-				if ev.GetPayload() != nil {
-					raw, err := protojson.Marshal(ev.GetPayload())
-					if err == nil {
-						payloadMap["payload"] = json.RawMessage(raw)
-					}
-				}
-				notif := map[string]any{
-					"jsonrpc": "2.0",
-					"method":  "notifications/event",
-					"params":  payloadMap,
-				}
-				data, err := json.Marshal(notif)
-				if err != nil {
-					continue
-				}
-				data = append(data, '\n')
-				t.mu.Lock()
-				_, writeErr := t.writer.Write(data)
-				t.mu.Unlock()
-				if writeErr != nil {
+				if err := t.handleEvent(ev); err != nil {
 					return
 				}
 			}
 		}()
 	}
 
-	for t.reader.Scan() {
+	for {
+		line, err := t.reader.next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
 		if ctx.Err() != nil {
 			return ctx.Err()
 		}
+		if errors.Is(err, errFrameTooLarge) {
+			resp := &protocol.JSONRPCResponse{
+				JSONRPC: "2.0",
+				ID:      nil,
+				Error: &protocol.JSONRPCError{
+					Code:    protocol.InvalidRequest,
+					Message: err.Error(),
+				},
+			}
+			if writeErr := t.writeResponse(resp); writeErr != nil {
+				return fmt.Errorf("write oversized message response: %w", writeErr)
+			}
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("read error: %w", err)
+		}
+
+		if len(line) == 0 {
+			continue
+		}
 
-		line := strings.TrimSpace(t.reader.Text())
-		if line == "" {
+		// A batch is answered with a single array once all of its requests
+		// complete. Its tool calls run concurrently, so the array is written
+		// from a goroutine to keep reading cancellations and other requests.
+		if isBatch(line) {
+			collect, errResp := t.startBatch(ctx, line)
+			if errResp != nil {
+				if err := t.writeResponse(errResp); err != nil {
+					return fmt.Errorf("write batch error response: %w", err)
+				}
+				continue
+			}
+			t.wg.Add(1)
+			go func() {
+				defer t.wg.Done()
+				if err := t.writeBatch(collect()); err != nil {
+					slog.Error("failed writing batch response", "error", err)
+				}
+			}()
 			continue
 		}
 
 		var req protocol.JSONRPCRequest
-		if err := json.Unmarshal([]byte(line), &req); err != nil {
+		if err := json.Unmarshal(line, &req); err != nil {
 			resp := &protocol.JSONRPCResponse{
 				JSONRPC: "2.0",
 				ID:      nil,
@@ -173,15 +263,31 @@ func (t *StdioTransport) Run(ctx context.Context) error {
 			continue
 		}
 
+		// A message without a method may answer a request sent to the client.
+		if req.Method == "" && t.deliverClientResponse(line) {
+			continue
+		}
+
+		if resp, ok := t.admit(&req); !ok {
+			if resp != nil {
+				if err := t.writeResponse(resp); err != nil {
+					return fmt.Errorf("write response: %w", err)
+				}
+			}
+			continue
+		}
+
 		// Dispatch tools/call concurrently so long-running calls don't block
 		// the read loop. Other methods (initialize, ping, list) are fast and
 		// handled inline to preserve ordering where it matters.
+		// Each call gets its own context so notifications/cancelled can abort
+		// it; a cancelled call's late response is dropped.
 		if req.Method == "tools/call" {
-			wg.Add(1)
+			reqCtx, done := t.trackRequest(ctx, req.ID)
+			t.wg.Add(1)
 			go func(r protocol.JSONRPCRequest) {
-				defer wg.Done()
-				resp := t.dispatch(ctx, &r)
-				if resp != nil {
+				defer t.wg.Done()
+				if resp := t.dispatchTracked(ctx, reqCtx, done, &r); resp != nil {
 					if err := t.writeResponse(resp); err != nil {
 						slog.Error("failed writing async response", "method", r.Method, "error", err)
 					}
@@ -201,18 +307,20 @@ func (t *StdioTransport) Run(ctx context.Context) error {
 			return fmt.Errorf("write response: %w", err)
 		}
 	}
-
-	if err := t.reader.Err(); err != nil {
-		return fmt.Errorf("scanner error: %w", err)
-	}
-
-	return nil
 }
 
-// dispatch routes a JSON-RPC request to the appropriate handler based on the
-// method field. Notifications (methods starting with "notifications/") return
-// nil to indicate no response should be written.
+// dispatch handles a JSON-RPC request under its configured timeout.
+// Notifications (methods starting with "notifications/") return nil to
+// indicate no response should be written.
 func (t *StdioTransport) dispatch(ctx context.Context, req *protocol.JSONRPCRequest) *protocol.JSONRPCResponse {
+	ctx, stop := t.withTimeout(ctx, req)
+	defer stop()
+	return t.timedOut(ctx, req, t.route(ctx, req))
+}
+
+// route passes a JSON-RPC request to the appropriate handler based on the
+// method field.
+func (t *StdioTransport) route(ctx context.Context, req *protocol.JSONRPCRequest) *protocol.JSONRPCResponse {
 	switch req.Method {
 	case "initialize":
 		return t.handleInitialize(req)
@@ -234,6 +342,21 @@ func (t *StdioTransport) dispatch(ctx context.Context, req *protocol.JSONRPCRequ
 		return t.handleResourcesRead(ctx, req)
 	case "resources/templates/list":
 		return t.handleResourceTemplatesList(req)
+	case "resources/subscribe":
+		return t.handleResourcesSubscribe(req)
+	case "resources/unsubscribe":
+		return t.handleResourcesUnsubscribe(req)
+	case "completion/complete":
+		return t.handleComplete(ctx, req)
+	case "notifications/initialized":
+		t.handleInitialized()
+		return nil
+	case "notifications/roots/list_changed":
+		t.refreshRoots()
+		return nil
+	case "notifications/cancelled":
+		t.handleCancelled(req)
+		return nil
 	default:
 		// Notifications get no response.
 		if strings.HasPrefix(req.Method, "notifications/") {
@@ -251,10 +374,134 @@ func (t *StdioTransport) dispatch(ctx context.Context, req *protocol.JSONRPCRequ
 	}
 }
 
+// dispatchTracked dispatches a request registered with trackRequest and marks
+// it done. If the client cancelled the request meanwhile, the cancellation is
+// forwarded to the orchestrator and nil is returned so no response is sent.
+func (t *StdioTransport) dispatchTracked(ctx, reqCtx context.Context, done func(), req *protocol.JSONRPCRequest) *protocol.JSONRPCResponse {
+	resp := t.dispatch(reqCtx, req)
+	done()
+	if errors.Is(context.Cause(reqCtx), errRequestCancelled) {
+		slog.Debug("dropping response for cancelled request", "id", req.ID)
+		t.propagateCancel(ctx, req.ID)
+		return nil
+	}
+	return resp
+}
+
+// requestKey normalizes a JSON-RPC ID into a map key. The ID is JSON-encoded
+// so that the number 1 and the string "1" remain distinct.
+func requestKey(id any) string {
+	raw, err := json.Marshal(id)
+	if err != nil {
+		return fmt.Sprintf("%v", id)
+	}
+	return string(raw)
+}
+
+// trackRequest registers an in-flight request under its JSON-RPC ID and returns
+// a context derived from ctx that is cancelled when the client cancels the
+// request. The returned done func must be called once the request completes.
+func (t *StdioTransport) trackRequest(ctx context.Context, id any) (context.Context, func()) {
+	reqCtx, cancel := context.WithCancelCause(ctx)
+	if id == nil {
+		return reqCtx, func() { cancel(nil) }
+	}
+
+	key := requestKey(id)
+	t.inflightMu.Lock()
+	if t.inflight == nil {
+		t.inflight = make(map[string]context.CancelCauseFunc)
+	}
+	t.inflight[key] = cancel
+	t.inflightMu.Unlock()
+
+	return reqCtx, func() {
+		t.inflightMu.Lock()
+		delete(t.inflight, key)
+		t.inflightMu.Unlock()
+		cancel(nil)
+	}
+}
+
+// cancelRequest cancels the in-flight request with the given JSON-RPC ID.
+// It reports whether a matching request was found.
+func (t *StdioTransport) cancelRequest(id any) bool {
+	key := requestKey(id)
+	t.inflightMu.Lock()
+	cancel, ok := t.inflight[key]
+	delete(t.inflight, key)
+	t.inflightMu.Unlock()
+	if ok {
+		cancel(errRequestCancelled)
+	}
+	return ok
+}
+
+// background runs fn in a goroutine that closeSession waits for. It reports
+// false, without running fn, if the session has already closed.
+func (t *StdioTransport) background(fn func()) bool {
+	t.clientMu.Lock()
+	defer t.clientMu.Unlock()
+	if t.closed {
+		return false
+	}
+	t.wg.Add(1)
+	go func() {
+		defer t.wg.Done()
+		fn()
+	}()
+	return true
+}
+
+// closeSession ends the session: it moves to StateShuttingDown, pending and
+// later requests to the client fail, and no new background work starts. It
+// then waits for the requests and background work already running.
+func (t *StdioTransport) closeSession() {
+	t.shutdown()
+	t.failClientRequests(errSessionClosed)
+	t.wg.Wait()
+}
+
+// send forwards a request to the orchestrator, returning early if ctx is
+// cancelled so that a cancelled call releases its goroutine even when the
+// Sender is still waiting for the orchestrator to answer.
+func (t *StdioTransport) send(ctx context.Context, req *pluginv1.PluginRequest) (*pluginv1.PluginResponse, error) {
+	type result struct {
+		resp *pluginv1.PluginResponse
+		err  error
+	}
+	ch := make(chan result, 1)
+	go func() {
+		resp, err := t.sender.Send(ctx, req)
+		ch <- result{resp, err}
+	}()
+
+	select {
+	case r := <-ch:
+		return r.resp, r.err
+	case <-ctx.Done():
+		return nil, context.Cause(ctx)
+	}
+}
+
 // writeResponse serializes a JSON-RPC response as a single JSON line and writes
 // it to the output. Access to the writer is serialized with a mutex.
 func (t *StdioTransport) writeResponse(resp *protocol.JSONRPCResponse) error {
-	data, err := json.Marshal(resp)
+	return t.writeLine(resp)
+}
+
+// writeBatch writes the responses to a batch as a single JSON array line. An
+// empty batch response (all notifications) writes nothing.
+func (t *StdioTransport) writeBatch(resps []*protocol.JSONRPCResponse) error {
+	if len(resps) == 0 {
+		return nil
+	}
+	return t.writeLine(resps)
+}
+
+// writeLine marshals v and writes it to the output as a single JSON line.
+func (t *StdioTransport) writeLine(v any) error {
+	data, err := json.Marshal(v)
 	if err != nil {
 		return fmt.Errorf("marshal response: %w", err)
 	}
@@ -267,45 +514,105 @@ func (t *StdioTransport) writeResponse(resp *protocol.JSONRPCResponse) error {
 	return err
 }
 
-// SendToolsListChanged sends a notifications/tools/list_changed JSON-RPC
-// notification to inform the client that the tool list has been updated.
-func (t *StdioTransport) SendToolsListChanged() {
+// handleEvent routes a single EventDelivery from the orchestrator. Progress
+// events for in-flight tool calls become notifications/progress. Sampling and
+// elicitation requests are forwarded to the client in the background, unless
+// they name another session. Storage
+// events invalidate cached reads and produce resource notifications, and
+// plugin events invalidate the cached tool and prompt lists, before being
+// pushed, like every other event, as a generic notifications/event. An error
+// means the notification could not be written and the event loop should stop.
+func (t *StdioTransport) handleEvent(ev *pluginv1.EventDelivery) error {
+	switch ev.GetTopic() {
+	case progressEventTopic:
+		return t.handleProgressEvent(ev)
+	case samplingRequestTopic, elicitationRequestTopic:
+		if t.addressedTo(ev) {
+			t.forwardClientRequest(ev)
+		}
+		return nil
+	case storageWriteTopic, storageDeleteTopic:
+		if err := t.handleStorageEvent(ev); err != nil {
+			return err
+		}
+	case pluginRegisteredTopic, pluginUnregisteredTopic:
+		t.handlePluginEvent(ev)
+	}
+	return t.pushEvent(ev)
+}
+
+// pushEvent writes an EventDelivery as a notifications/event JSON-RPC
+// notification. IDE clients that don't understand these notifications safely
+// ignore them per the JSON-RPC spec.
+func (t *StdioTransport) pushEvent(ev *pluginv1.EventDelivery) error {
+	payloadMap := map[string]any{
+		"topic":      ev.GetTopic(),
+		"event_type": ev.GetEventType(),
+		"source":     ev.GetSourcePlugin(),
+	}
+	if ev.GetPayload() != nil {
+		raw, err := protojson.Marshal(ev.GetPayload())
+		if err == nil {
+			payloadMap["payload"] = json.RawMessage(raw)
+		}
+	}
+	return t.writeNotification("notifications/event", payloadMap)
+}
+
+// writeNotification serializes a JSON-RPC notification (a message without an
+// ID) as a single JSON line and writes it to the output. A nil params value is
+// omitted from the message.
+func (t *StdioTransport) writeNotification(method string, params any) error {
 	notif := map[string]any{
 		"jsonrpc": "2.0",
-		"method":  "notifications/tools/list_changed",
+		"method":  method,
+	}
+	if params != nil {
+		notif["params"] = params
 	}
 	raw, err := json.Marshal(notif)
 	if err != nil {
-		return
+		return fmt.Errorf("marshal notification: %w", err)
 	}
 	raw = append(raw, '\n')
+
 	t.mu.Lock()
-	t.writer.Write(raw)
-	t.mu.Unlock()
+	defer t.mu.Unlock()
+	_, err = t.writer.Write(raw)
+	return err
+}
+
+// SendToolsListChanged sends a notifications/tools/list_changed JSON-RPC
+// notification to inform the client that the tool list has been updated. The
+// cached tool list is dropped so the client's next tools/list sees the change.
+func (t *StdioTransport) SendToolsListChanged() {
+	t.lists.dropTools()
+	t.writeNotification("notifications/tools/list_changed", nil)
+}
+
+// SendPromptsListChanged sends a notifications/prompts/list_changed JSON-RPC
+// notification to inform the client that the prompt list has been updated.
+// The cached prompt list is dropped so the client's next prompts/list sees the
+// change.
+func (t *StdioTransport) SendPromptsListChanged() {
+	t.lists.dropPrompts()
+	t.writeNotification("notifications/prompts/list_changed", nil)
 }
 
 // SendLogNotification sends a notifications/message JSON-RPC notification to
 // the client if the message's level meets or exceeds the configured threshold.
+// It may be called from any goroutine.
 func (t *StdioTransport) SendLogNotification(level protocol.MCPLogLevel, logger, data string) {
-	if protocol.LogLevelSeverity(level) < protocol.LogLevelSeverity(t.logLevel) {
+	t.mu.Lock()
+	threshold := t.logLevel
+	t.mu.Unlock()
+	if protocol.LogLevelSeverity(level) < protocol.LogLevelSeverity(threshold) {
 		return
 	}
 
-	notif := map[string]any{
-		"jsonrpc": "2.0",
-		"method":  "notifications/message",
-		"params": map[string]any{
-			"level":  string(level),
-			"logger": logger,
-			"data":   data,
-		},
-	}
-	raw, err := json.Marshal(notif)
-	if err != nil {
-		return
-	}
-	raw = append(raw, '\n')
-	t.mu.Lock()
-	t.writer.Write(raw)
-	t.mu.Unlock()
+	t.writeNotification("notifications/message", map[string]any{
+		"level":  string(level),
+		"logger": logger,
+		"data":   data,
+	})
 }
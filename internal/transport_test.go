@@ -5,8 +5,11 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	pluginv1 "github.com/orchestra-mcp/gen-go/orchestra/plugin/v1"
 	"github.com/orchestra-mcp/sdk-go/protocol"
@@ -33,12 +36,43 @@ func runSingleRequest(t *testing.T, sender Sender, requestJSON string) string {
 	in := strings.NewReader(requestJSON + "\n")
 	var out bytes.Buffer
 	transport := NewStdioTransport(sender, in, &out)
+	// An initialize request is sent as is; anything else, including batches
+	// and malformed input, needs a completed handshake.
+	var probe struct {
+		Method string `json:"method"`
+	}
+	json.Unmarshal([]byte(requestJSON), &probe)
+	if probe.Method != "initialize" {
+		handshake(t, transport)
+	}
 	if err := transport.Run(context.Background()); err != nil {
 		t.Fatalf("Run failed: %v", err)
 	}
 	return strings.TrimSpace(out.String())
 }
 
+// handshake completes the MCP initialize handshake on a transport so tests can
+// exercise the methods that require it.
+func handshake(t *testing.T, tr *StdioTransport) {
+	t.Helper()
+	ctx := context.Background()
+	resp := tr.dispatch(ctx, &protocol.JSONRPCRequest{
+		JSONRPC: "2.0", ID: 0, Method: "initialize", Params: json.RawMessage(`{"protocolVersion":"2025-06-18"}`),
+	})
+	if resp.Error != nil {
+		t.Fatalf("initialize: %+v", resp.Error)
+	}
+	tr.dispatch(ctx, &protocol.JSONRPCRequest{JSONRPC: "2.0", Method: "notifications/initialized"})
+}
+
+// newReadyTransport creates a StdioTransport that has completed the handshake.
+func newReadyTransport(t *testing.T, sender Sender, in io.Reader, out io.Writer, opts ...func(*StdioTransport)) *StdioTransport {
+	t.Helper()
+	tr := NewStdioTransport(sender, in, out, opts...)
+	handshake(t, tr)
+	return tr
+}
+
 // parseJSONRPCResponse parses a JSON-RPC response string.
 func parseJSONRPCResponse(t *testing.T, raw string) protocol.JSONRPCResponse {
 	t.Helper()
@@ -74,8 +108,8 @@ func TestInitialize(t *testing.T) {
 		t.Fatalf("unmarshal init result: %v", err)
 	}
 
-	if initResult.ProtocolVersion != protocol.MCPProtocolVersion {
-		t.Errorf("protocolVersion: got %q, want %q", initResult.ProtocolVersion, protocol.MCPProtocolVersion)
+	if initResult.ProtocolVersion != "2025-06-18" {
+		t.Errorf("protocolVersion: got %q, want %q", initResult.ProtocolVersion, "2025-06-18")
 	}
 	if initResult.ServerInfo.Name != "orchestra" {
 		t.Errorf("serverInfo.name: got %q, want %q", initResult.ServerInfo.Name, "orchestra")
@@ -404,7 +438,7 @@ func TestEmptyLines(t *testing.T) {
 	input := "\n\n" + `{"jsonrpc":"2.0","id":1,"method":"ping"}` + "\n\n"
 	in := strings.NewReader(input)
 	var out bytes.Buffer
-	transport := NewStdioTransport(&mockSender{}, in, &out)
+	transport := newReadyTransport(t, &mockSender{}, in, &out)
 	if err := transport.Run(context.Background()); err != nil {
 		t.Fatalf("Run failed: %v", err)
 	}
@@ -434,7 +468,10 @@ func TestInitializeHasPromptsCapability(t *testing.T) {
 	}
 
 	if initResult.Capabilities.Prompts == nil {
-		t.Error("expected capabilities.prompts to be set")
+		t.Fatal("expected capabilities.prompts to be set")
+	}
+	if !initResult.Capabilities.Prompts.ListChanged {
+		t.Error("expected capabilities.prompts.listChanged to be true")
 	}
 }
 
@@ -889,7 +926,7 @@ func TestLoggingSetLevelPersists(t *testing.T) {
 
 	in := strings.NewReader(input)
 	var out bytes.Buffer
-	transport := NewStdioTransport(&mockSender{}, in, &out)
+	transport := newReadyTransport(t, &mockSender{}, in, &out)
 	if err := transport.Run(context.Background()); err != nil {
 		t.Fatalf("Run failed: %v", err)
 	}
@@ -993,6 +1030,38 @@ func TestSendLogNotificationAtThreshold(t *testing.T) {
 	}
 }
 
+func TestSetLevelWhileSendingLogNotifications(t *testing.T) {
+	pr, pw := io.Pipe()
+	transport := newReadyTransport(t, &mockSender{}, pr, io.Discard)
+	done := make(chan error, 1)
+	go func() { done <- transport.Run(context.Background()) }()
+
+	// Run with -race: log notifications may come from other goroutines,
+	// such as the HTTP fan-out, while the request loop changes the level.
+	stop := make(chan struct{})
+	sent := make(chan struct{})
+	go func() {
+		defer close(sent)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				transport.SendLogNotification(protocol.LogLevelDebug, "test", "tick")
+			}
+		}
+	}()
+	for i, level := range []string{"error", "critical", "warning"} {
+		fmt.Fprintf(pw, `{"jsonrpc":"2.0","id":%d,"method":"logging/setLevel","params":{"level":"%s"}}`+"\n", i, level)
+	}
+	close(stop)
+	<-sent
+	pw.Close()
+	if err := <-done; err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+}
+
 func TestLogLevelSeverity(t *testing.T) {
 	cases := []struct {
 		level    protocol.MCPLogLevel
@@ -1305,3 +1374,552 @@ func TestToolsListNetworkError(t *testing.T) {
 		t.Errorf("error code: got %d, want %d", resp.Error.Code, protocol.InternalError)
 	}
 }
+
+// --- Cancellation tests ---
+
+// blockingSender blocks tool calls named "slow" until their context is
+// cancelled, answers all other tool calls immediately, and records the
+// StreamCancel requests it receives.
+type blockingSender struct {
+	slowErr   chan error // receives the context error observed by a blocked call
+	mu        sync.Mutex
+	cancelled []string // stream IDs from StreamCancel requests
+}
+
+func (b *blockingSender) Send(ctx context.Context, req *pluginv1.PluginRequest) (*pluginv1.PluginResponse, error) {
+	if sc := req.GetStreamCancel(); sc != nil {
+		b.mu.Lock()
+		b.cancelled = append(b.cancelled, sc.StreamId)
+		b.mu.Unlock()
+		return &pluginv1.PluginResponse{RequestId: req.RequestId}, nil
+	}
+
+	tc := req.GetToolCall()
+	if tc == nil {
+		return nil, fmt.Errorf("unexpected request %T", req.Request)
+	}
+	if tc.ToolName == "slow" {
+		<-ctx.Done()
+		b.slowErr <- ctx.Err()
+		return nil, ctx.Err()
+	}
+
+	result, _ := structpb.NewStruct(map[string]any{"text": "done"})
+	return &pluginv1.PluginResponse{
+		RequestId: req.RequestId,
+		Response: &pluginv1.PluginResponse_ToolCall{
+			ToolCall: &pluginv1.ToolResponse{Success: true, Result: result},
+		},
+	}, nil
+}
+
+func TestToolsCallCancelled(t *testing.T) {
+	input := strings.Join([]string{
+		`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"slow"}}`,
+		`{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"fast"}}`,
+		`{"jsonrpc":"2.0","method":"notifications/cancelled","params":{"requestId":1,"reason":"user aborted"}}`,
+	}, "\n") + "\n"
+
+	sender := &blockingSender{slowErr: make(chan error, 1)}
+	var out bytes.Buffer
+	transport := newReadyTransport(t, sender, strings.NewReader(input), &out)
+
+	done := make(chan error, 1)
+	go func() { done <- transport.Run(context.Background()) }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after the slow call was cancelled")
+	}
+
+	// Only the fast call should have produced a response.
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("expected 1 response line, got %d: %v", len(lines), lines)
+	}
+	resp := parseJSONRPCResponse(t, lines[0])
+	if resp.ID != float64(2) {
+		t.Errorf("response id: got %v, want 2", resp.ID)
+	}
+
+	select {
+	case err := <-sender.slowErr:
+		if err != context.Canceled {
+			t.Errorf("slow call context error: got %v, want %v", err, context.Canceled)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("slow call was never cancelled")
+	}
+
+	sender.mu.Lock()
+	defer sender.mu.Unlock()
+	if want := transport.toolCallRequestID(1); len(sender.cancelled) != 1 || sender.cancelled[0] != want {
+		t.Errorf("stream cancels: got %v, want [%s]", sender.cancelled, want)
+	}
+	if len(transport.inflight) != 0 {
+		t.Errorf("expected no in-flight requests, got %d", len(transport.inflight))
+	}
+}
+
+func TestCancelUnknownRequestIgnored(t *testing.T) {
+	input := strings.Join([]string{
+		`{"jsonrpc":"2.0","method":"notifications/cancelled","params":{"requestId":"missing"}}`,
+		`{"jsonrpc":"2.0","id":"a","method":"tools/call","params":{"name":"fast"}}`,
+	}, "\n") + "\n"
+
+	sender := &blockingSender{}
+	var out bytes.Buffer
+	transport := newReadyTransport(t, sender, strings.NewReader(input), &out)
+	if err := transport.Run(context.Background()); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	resp := parseJSONRPCResponse(t, strings.TrimSpace(out.String()))
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %+v", resp.Error)
+	}
+	if resp.ID != "a" {
+		t.Errorf("response id: got %v, want %q", resp.ID, "a")
+	}
+	if len(sender.cancelled) != 0 {
+		t.Errorf("expected no stream cancels, got %v", sender.cancelled)
+	}
+}
+
+func TestRequestKeyDistinguishesTypes(t *testing.T) {
+	if requestKey(float64(1)) == requestKey("1") {
+		t.Error("numeric and string IDs should produce distinct keys")
+	}
+	if requestKey(float64(7)) != requestKey(float64(7)) {
+		t.Error("equal IDs should produce equal keys")
+	}
+}
+
+// --- Progress tests ---
+
+// lineWriter is a concurrency-safe writer that also forwards each written line
+// to a channel so tests can wait for asynchronous output.
+type lineWriter struct {
+	mu    sync.Mutex
+	buf   bytes.Buffer
+	lines chan string
+}
+
+func newLineWriter() *lineWriter {
+	return &lineWriter{lines: make(chan string, 64)}
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.buf.Write(p)
+	w.lines <- strings.TrimSpace(string(p))
+	return len(p), nil
+}
+
+// next waits for the next written line.
+func (w *lineWriter) next(t *testing.T) string {
+	t.Helper()
+	select {
+	case line := <-w.lines:
+		return line
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for output")
+		return ""
+	}
+}
+
+func progressEvent(t *testing.T, payload map[string]any) *pluginv1.EventDelivery {
+	t.Helper()
+	s, err := structpb.NewStruct(payload)
+	if err != nil {
+		t.Fatalf("progress payload: %v", err)
+	}
+	return &pluginv1.EventDelivery{Topic: progressEventTopic, Payload: s}
+}
+
+func TestToolsCallProgress(t *testing.T) {
+	events := make(chan *pluginv1.EventDelivery, 4)
+	out := newLineWriter()
+	relayed := make(chan struct{})
+
+	sender := &mockSender{
+		sendFunc: func(ctx context.Context, req *pluginv1.PluginRequest) (*pluginv1.PluginResponse, error) {
+			// Report progress while the call is running and wait until it
+			// has been relayed before completing.
+			events <- progressEvent(t, map[string]any{
+				"request_id": req.RequestId,
+				"progress":   3,
+				"total":      10,
+				"message":    "indexing",
+			})
+			if line := out.next(t); !strings.Contains(line, "notifications/progress") {
+				t.Errorf("expected progress notification, got %s", line)
+			}
+			close(relayed)
+
+			result, _ := structpb.NewStruct(map[string]any{"text": "done"})
+			return &pluginv1.PluginResponse{
+				RequestId: req.RequestId,
+				Response: &pluginv1.PluginResponse_ToolCall{
+					ToolCall: &pluginv1.ToolResponse{Success: true, Result: result},
+				},
+			}, nil
+		},
+	}
+
+	reqJSON := `{"jsonrpc":"2.0","id":9,"method":"tools/call","params":{"name":"index","_meta":{"progressToken":"tok-1"}}}`
+	transport := newReadyTransport(t, sender, strings.NewReader(reqJSON+"\n"), out, WithEventChannel(events))
+
+	done := make(chan error, 1)
+	go func() { done <- transport.Run(context.Background()) }()
+
+	<-relayed
+	resp := parseJSONRPCResponse(t, out.next(t))
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %+v", resp.Error)
+	}
+
+	// Progress arriving after completion must be dropped.
+	events <- progressEvent(t, map[string]any{"request_id": transport.toolCallRequestID(9), "progress": 10})
+	close(events)
+	if err := <-done; err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected progress + response lines, got %d: %v", len(lines), lines)
+	}
+
+	var notif struct {
+		Method string         `json:"method"`
+		Params progressParams `json:"params"`
+	}
+	if err := json.Unmarshal([]byte(lines[0]), &notif); err != nil {
+		t.Fatalf("parse notification: %v", err)
+	}
+	if notif.Method != "notifications/progress" {
+		t.Errorf("method: got %q", notif.Method)
+	}
+	if notif.Params.ProgressToken != "tok-1" {
+		t.Errorf("progressToken: got %v, want %q", notif.Params.ProgressToken, "tok-1")
+	}
+	if notif.Params.Progress != 3 || notif.Params.Total != 10 {
+		t.Errorf("progress: got %v/%v, want 3/10", notif.Params.Progress, notif.Params.Total)
+	}
+	if notif.Params.Message != "indexing" {
+		t.Errorf("message: got %q", notif.Params.Message)
+	}
+	if len(transport.progressTokens) != 0 {
+		t.Errorf("expected progress mapping to be cleaned up, got %v", transport.progressTokens)
+	}
+}
+
+func TestRequestIDsDifferAcrossSessions(t *testing.T) {
+	a := newReadyTransport(t, &mockSender{}, nil, nil)
+	b := newReadyTransport(t, &mockSender{}, nil, nil)
+
+	// Sessions sharing a Sender reuse JSON-RPC IDs; their orchestrator
+	// request IDs, which progress events and stream cancels name, must not
+	// collide.
+	if a.toolCallRequestID(1) == b.toolCallRequestID(1) {
+		t.Errorf("both sessions use request ID %q", a.toolCallRequestID(1))
+	}
+	if want := "stdio-tc-" + a.sessionID + "-1"; a.toolCallRequestID(1) != want {
+		t.Errorf("request ID: got %q, want %q", a.toolCallRequestID(1), want)
+	}
+}
+
+func TestProgressEventWithoutTokenDropped(t *testing.T) {
+	var out bytes.Buffer
+	transport := &StdioTransport{writer: &out}
+
+	ev := progressEvent(t, map[string]any{"request_id": "stdio-tc-1", "progress": 1})
+	if err := transport.handleEvent(ev); err != nil {
+		t.Fatalf("handleEvent: %v", err)
+	}
+	if out.Len() != 0 {
+		t.Errorf("expected no output for untracked progress, got: %s", out.String())
+	}
+}
+
+// --- Pagination tests ---
+
+// toolListSender answers ListTools with the named tools, read from *names on
+// every call so tests can change the list between pages.
+func toolListSender(names *[]string) *mockSender {
+	return &mockSender{
+		sendFunc: func(ctx context.Context, req *pluginv1.PluginRequest) (*pluginv1.PluginResponse, error) {
+			tools := make([]*pluginv1.ToolDefinition, 0, len(*names))
+			for _, n := range *names {
+				tools = append(tools, &pluginv1.ToolDefinition{Name: n})
+			}
+			return &pluginv1.PluginResponse{
+				Response: &pluginv1.PluginResponse_ListTools{
+					ListTools: &pluginv1.ListToolsResponse{Tools: tools},
+				},
+			}, nil
+		},
+	}
+}
+
+// listToolsPage dispatches a tools/list request with the given cursor and
+// returns the decoded result or error.
+func listToolsPage(t *testing.T, transport *StdioTransport, cursor string) (toolsListResult, *protocol.JSONRPCError) {
+	t.Helper()
+	params, _ := json.Marshal(listParams{Cursor: cursor})
+	resp := transport.dispatch(context.Background(), &protocol.JSONRPCRequest{
+		JSONRPC: "2.0",
+		ID:      1,
+		Method:  "tools/list",
+		Params:  params,
+	})
+	if resp.Error != nil {
+		return toolsListResult{}, resp.Error
+	}
+	return resp.Result.(toolsListResult), nil
+}
+
+func toolNames(r toolsListResult) []string {
+	names := make([]string, len(r.Tools))
+	for i, td := range r.Tools {
+		names[i] = td.Name
+	}
+	return names
+}
+
+func TestToolsListPagination(t *testing.T) {
+	names := []string{"a", "b", "c", "d", "e"}
+	transport := newReadyTransport(t, toolListSender(&names), nil, io.Discard, WithPageSize(2))
+
+	var got [][]string
+	cursor := ""
+	for {
+		page, rpcErr := listToolsPage(t, transport, cursor)
+		if rpcErr != nil {
+			t.Fatalf("unexpected error: %+v", rpcErr)
+		}
+		got = append(got, toolNames(page))
+		if page.NextCursor == "" {
+			break
+		}
+		cursor = page.NextCursor
+	}
+
+	want := [][]string{{"a", "b"}, {"c", "d"}, {"e"}}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("pages: got %v, want %v", got, want)
+	}
+}
+
+func TestToolsListPaginationDisabled(t *testing.T) {
+	names := []string{"a", "b", "c"}
+	transport := newReadyTransport(t, toolListSender(&names), nil, io.Discard)
+
+	page, rpcErr := listToolsPage(t, transport, "")
+	if rpcErr != nil {
+		t.Fatalf("unexpected error: %+v", rpcErr)
+	}
+	if len(page.Tools) != 3 || page.NextCursor != "" {
+		t.Errorf("expected all 3 tools without cursor, got %v (next %q)", toolNames(page), page.NextCursor)
+	}
+}
+
+func TestToolsListCursorSurvivesInsertion(t *testing.T) {
+	names := []string{"a", "b", "c", "d"}
+	transport := newReadyTransport(t, toolListSender(&names), nil, io.Discard, WithPageSize(2))
+
+	first, _ := listToolsPage(t, transport, "")
+	names = []string{"0", "a", "b", "c", "d"}
+
+	second, rpcErr := listToolsPage(t, transport, first.NextCursor)
+	if rpcErr != nil {
+		t.Fatalf("unexpected error: %+v", rpcErr)
+	}
+	if got := toolNames(second); fmt.Sprint(got) != "[c d]" {
+		t.Errorf("second page: got %v, want [c d]", got)
+	}
+}
+
+func TestToolsListStaleCursor(t *testing.T) {
+	names := []string{"a", "b", "c", "d"}
+	transport := newReadyTransport(t, toolListSender(&names), nil, io.Discard, WithPageSize(2))
+
+	first, _ := listToolsPage(t, transport, "")
+	names = []string{"a", "c", "d"}
+
+	_, rpcErr := listToolsPage(t, transport, first.NextCursor)
+	if rpcErr == nil {
+		t.Fatal("expected error for stale cursor")
+	}
+	if rpcErr.Code != protocol.InvalidParams {
+		t.Errorf("error code: got %d, want %d", rpcErr.Code, protocol.InvalidParams)
+	}
+}
+
+func TestListInvalidCursor(t *testing.T) {
+	names := []string{"a", "b", "c"}
+	transport := newReadyTransport(t, toolListSender(&names), nil, io.Discard, WithPageSize(1))
+	other := newReadyTransport(t, toolListSender(&names), nil, io.Discard, WithPageSize(1))
+
+	first, _ := listToolsPage(t, transport, "")
+	tampered := strings.Replace(first.NextCursor, ".", "x.", 1)
+	foreign, _ := listToolsPage(t, other, "")
+
+	cases := map[string]string{
+		"garbage":       "not-a-cursor",
+		"tampered":      tampered,
+		"other session": foreign.NextCursor,
+	}
+	for name, cursor := range cases {
+		_, rpcErr := listToolsPage(t, transport, cursor)
+		if rpcErr == nil {
+			t.Errorf("%s: expected error", name)
+			continue
+		}
+		if rpcErr.Code != protocol.InvalidParams {
+			t.Errorf("%s: error code: got %d, want %d", name, rpcErr.Code, protocol.InvalidParams)
+		}
+	}
+
+	// A tools/list cursor must not be accepted by prompts/list.
+	params, _ := json.Marshal(listParams{Cursor: first.NextCursor})
+	resp := transport.dispatch(context.Background(), &protocol.JSONRPCRequest{
+		JSONRPC: "2.0", ID: 2, Method: "prompts/list", Params: params,
+	})
+	if resp.Error == nil || resp.Error.Code != protocol.InvalidParams {
+		t.Errorf("expected InvalidParams for cross-method cursor, got %+v", resp.Error)
+	}
+}
+
+// --- Resource subscription tests ---
+
+func TestInitializeAdvertisesResourceSubscribe(t *testing.T) {
+	raw := runSingleRequest(t, &mockSender{}, `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`)
+
+	var resp struct {
+		Result struct {
+			Capabilities struct {
+				Resources resourcesCapability `json:"resources"`
+			} `json:"capabilities"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal([]byte(raw), &resp); err != nil {
+		t.Fatalf("parse response: %v", err)
+	}
+	if !resp.Result.Capabilities.Resources.Subscribe {
+		t.Error("expected capabilities.resources.subscribe to be true")
+	}
+	if !resp.Result.Capabilities.Resources.ListChanged {
+		t.Error("expected capabilities.resources.listChanged to be true")
+	}
+}
+
+// storageEvent builds a storage event for path on the given topic.
+func storageEvent(t *testing.T, topic, path string, created bool) *pluginv1.EventDelivery {
+	t.Helper()
+	payload, err := structpb.NewStruct(map[string]any{"path": path, "created": created})
+	if err != nil {
+		t.Fatalf("storage payload: %v", err)
+	}
+	return &pluginv1.EventDelivery{Topic: topic, Payload: payload, SourcePlugin: "storage.markdown"}
+}
+
+// notificationMethods returns the method and params.uri of every
+// non-notifications/event line in out, in order.
+func notificationMethods(t *testing.T, out string) []string {
+	t.Helper()
+	var got []string
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		if line == "" {
+			continue
+		}
+		var notif struct {
+			Method string `json:"method"`
+			Params struct {
+				URI string `json:"uri"`
+			} `json:"params"`
+		}
+		if err := json.Unmarshal([]byte(line), &notif); err != nil {
+			t.Fatalf("parse notification: %v", err)
+		}
+		if notif.Method == "notifications/event" {
+			continue
+		}
+		got = append(got, strings.TrimSpace(notif.Method+" "+notif.Params.URI))
+	}
+	return got
+}
+
+func TestResourcesSubscribeNotifiesUpdates(t *testing.T) {
+	var out bytes.Buffer
+	transport := newReadyTransport(t, &mockSender{}, nil, &out)
+
+	resp := transport.dispatch(context.Background(), &protocol.JSONRPCRequest{
+		JSONRPC: "2.0", ID: 1, Method: "resources/subscribe",
+		Params: json.RawMessage(`{"uri":"orchestra://features/FEAT-1"}`),
+	})
+	if resp.Error != nil {
+		t.Fatalf("subscribe error: %+v", resp.Error)
+	}
+
+	events := []*pluginv1.EventDelivery{
+		storageEvent(t, storageWriteTopic, "features/FEAT-1.md", false),
+		storageEvent(t, storageWriteTopic, "features/FEAT-2.md", false),
+		storageEvent(t, storageWriteTopic, "notes/NOTE-1.md", true),
+		storageEvent(t, storageDeleteTopic, "features/FEAT-1.md", false),
+		storageEvent(t, storageWriteTopic, "unrelated/thing.md", true),
+	}
+	for _, ev := range events {
+		if err := transport.handleEvent(ev); err != nil {
+			t.Fatalf("handleEvent: %v", err)
+		}
+	}
+
+	got := notificationMethods(t, out.String())
+	want := []string{
+		"notifications/resources/updated orchestra://features/FEAT-1",
+		"notifications/resources/list_changed",
+		"notifications/resources/updated orchestra://features/FEAT-1",
+		"notifications/resources/list_changed",
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("notifications:\n got  %v\n want %v", got, want)
+	}
+
+	// Storage events are still forwarded as generic events.
+	if n := strings.Count(out.String(), `"notifications/event"`); n != len(events) {
+		t.Errorf("generic events: got %d, want %d", n, len(events))
+	}
+
+	// After unsubscribing, updates are no longer sent.
+	out.Reset()
+	transport.dispatch(context.Background(), &protocol.JSONRPCRequest{
+		JSONRPC: "2.0", ID: 2, Method: "resources/unsubscribe",
+		Params: json.RawMessage(`{"uri":"orchestra://features/FEAT-1"}`),
+	})
+	if err := transport.handleEvent(storageEvent(t, storageWriteTopic, "features/FEAT-1.md", false)); err != nil {
+		t.Fatalf("handleEvent: %v", err)
+	}
+	if got := notificationMethods(t, out.String()); len(got) != 0 {
+		t.Errorf("expected no resource notifications after unsubscribe, got %v", got)
+	}
+}
+
+func TestResourcesSubscribeInvalidURI(t *testing.T) {
+	for _, params := range []string{`{}`, `{"uri":"https://example.com"}`, `{"uri":"orchestra://unknown/x"}`} {
+		reqJSON := `{"jsonrpc":"2.0","id":1,"method":"resources/subscribe","params":` + params + `}`
+		resp := parseJSONRPCResponse(t, runSingleRequest(t, &mockSender{}, reqJSON))
+		if resp.Error == nil {
+			t.Errorf("%s: expected error", params)
+			continue
+		}
+		if resp.Error.Code != protocol.InvalidParams {
+			t.Errorf("%s: error code: got %d, want %d", params, resp.Error.Code, protocol.InvalidParams)
+		}
+	}
+}
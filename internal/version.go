@@ -0,0 +1,168 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/orchestra-mcp/sdk-go/protocol"
+)
+
+// MCP protocol revisions. Revisions are dates, so later revisions compare
+// greater as strings.
+const (
+	protocolVersion20241105 = "2024-11-05"
+	protocolVersion20250326 = "2025-03-26"
+	protocolVersion20250618 = "2025-06-18"
+)
+
+// supportedProtocolVersions lists the revisions this transport speaks, newest
+// first.
+var supportedProtocolVersions = []string{
+	protocolVersion20250618,
+	protocolVersion20250326,
+	protocolVersion20241105,
+}
+
+// Minimum protocol revisions of features that older clients do not
+// understand. They are passed to StdioTransport.supports.
+const (
+	featureToolAnnotations      = protocolVersion20250326
+	featureAudioContent         = protocolVersion20250326
+	featureCompletions          = protocolVersion20250326
+	featureStructuredContent    = protocolVersion20250618 // also tool outputSchema
+	featureToolTitle            = protocolVersion20250618
+	featureResourceLinks        = protocolVersion20250618
+	featureElicitation          = protocolVersion20250618
+	featureResourceLastModified = protocolVersion20250618
+)
+
+// initializeParams is the expected shape of params for an initialize request.
+type initializeParams struct {
+	ProtocolVersion string                 `json:"protocolVersion"`
+	Capabilities    clientCapabilities     `json:"capabilities"`
+	ClientInfo      protocol.MCPServerInfo `json:"clientInfo"`
+}
+
+// clientCapabilities are the capabilities a client declares in initialize.
+type clientCapabilities struct {
+	Roots *struct {
+		ListChanged bool `json:"listChanged,omitempty"`
+	} `json:"roots,omitempty"`
+	Sampling    *struct{} `json:"sampling,omitempty"`
+	Elicitation *struct{} `json:"elicitation,omitempty"`
+}
+
+// negotiateVersion picks the protocol revision for a session. A supported
+// requested revision is used as is. Any other revision date, newer or older,
+// gets the latest one this transport supports, leaving the client to decide
+// whether to proceed. Only a string that is not a revision date is rejected.
+// Clients that send no revision get protocol.MCPProtocolVersion, as before
+// negotiation existed.
+func negotiateVersion(requested string) (string, error) {
+	if requested == "" {
+		return protocol.MCPProtocolVersion, nil
+	}
+	for _, v := range supportedProtocolVersions {
+		if v == requested {
+			return v, nil
+		}
+	}
+	if _, err := time.Parse(time.DateOnly, requested); err == nil {
+		return supportedProtocolVersions[0], nil
+	}
+	return "", fmt.Errorf("unsupported protocol version %q: this server supports %s",
+		requested, strings.Join(supportedProtocolVersions, ", "))
+}
+
+// parseInitializeParams decodes initialize params and negotiates the protocol
+// revision, returning an error response if either fails.
+func parseInitializeParams(req *protocol.JSONRPCRequest) (initializeParams, string, *protocol.JSONRPCResponse) {
+	var params initializeParams
+	if req.Params != nil {
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return params, "", &protocol.JSONRPCResponse{
+				JSONRPC: "2.0",
+				ID:      req.ID,
+				Error: &protocol.JSONRPCError{
+					Code:    protocol.InvalidParams,
+					Message: fmt.Sprintf("invalid params: %v", err),
+				},
+			}
+		}
+	}
+
+	version, err := negotiateVersion(params.ProtocolVersion)
+	if err != nil {
+		return params, "", &protocol.JSONRPCResponse{
+			JSONRPC: "2.0",
+			ID:      req.ID,
+			Error: &protocol.JSONRPCError{
+				Code:    protocol.InvalidParams,
+				Message: err.Error(),
+				Data: map[string]any{
+					"supported": supportedProtocolVersions,
+					"requested": params.ProtocolVersion,
+				},
+			},
+		}
+	}
+	slog.Debug("negotiated protocol version",
+		"requested", params.ProtocolVersion, "version", version,
+		"client", params.ClientInfo.Name, "client_version", params.ClientInfo.Version)
+	return params, version, nil
+}
+
+// supports reports whether the session's negotiated protocol revision includes
+// a feature, given as the revision that introduced it. Before initialize the
+// latest revision is assumed.
+func (t *StdioTransport) supports(feature string) bool {
+	version := t.protocolVersion
+	if version == "" {
+		version = supportedProtocolVersions[0]
+	}
+	return version >= feature
+}
+
+// gateToolDefinition removes tool definition fields that the session's
+// protocol revision does not have.
+func (t *StdioTransport) gateToolDefinition(def ToolDefinition) ToolDefinition {
+	if !t.supports(featureToolTitle) {
+		def.Title = ""
+	}
+	if !t.supports(featureStructuredContent) {
+		def.OutputSchema = nil
+	}
+	if !t.supports(featureToolAnnotations) {
+		def.Annotations = nil
+	}
+	return def
+}
+
+// gateToolResult adapts a tool result to the session's protocol revision.
+// structuredContent is dropped, and content block types the revision lacks are
+// replaced by text describing them.
+func (t *StdioTransport) gateToolResult(result ToolResult) ToolResult {
+	if !t.supports(featureStructuredContent) {
+		result.StructuredContent = nil
+	}
+	audio, links := t.supports(featureAudioContent), t.supports(featureResourceLinks)
+	if audio && links {
+		return result
+	}
+
+	blocks := make([]ContentBlock, len(result.Content))
+	for i, b := range result.Content {
+		switch {
+		case b.Type == "audio" && !audio:
+			b = ContentBlock{Type: "text", Text: fmt.Sprintf("[audio (%s) omitted: not supported by protocol version %s]", b.MimeType, t.protocolVersion)}
+		case b.Type == "resource_link" && !links:
+			b = ContentBlock{Type: "text", Text: fmt.Sprintf("%s: %s", b.Name, b.URI)}
+		}
+		blocks[i] = b
+	}
+	result.Content = blocks
+	return result
+}
@@ -0,0 +1,117 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/orchestra-mcp/sdk-go/protocol"
+)
+
+func TestNegotiateVersion(t *testing.T) {
+	tests := []struct {
+		requested string
+		want      string
+		wantErr   bool
+	}{
+		{"2025-06-18", "2025-06-18", false},
+		{"2025-03-26", "2025-03-26", false},
+		{"2024-11-05", "2024-11-05", false},
+		{"2026-01-01", supportedProtocolVersions[0], false},
+		{"", protocol.MCPProtocolVersion, false},
+		{"2024-10-07", supportedProtocolVersions[0], false},
+		{"latest", "", true},
+		{"2025-13-01", "", true},
+	}
+	for _, tt := range tests {
+		got, err := negotiateVersion(tt.requested)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("negotiateVersion(%q) = %q, %v; want %q, error %v", tt.requested, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+// initializeWith sends an initialize request with the given protocol version.
+func initializeWith(t *testing.T, tr *StdioTransport, version string) protocol.JSONRPCResponse {
+	t.Helper()
+	params := `{"protocolVersion":"` + version + `","capabilities":{"roots":{"listChanged":true}},"clientInfo":{"name":"test","version":"1.0.0"}}`
+	return roundTrip(t, tr.dispatch(context.Background(), &protocol.JSONRPCRequest{
+		JSONRPC: "2.0", ID: 0, Method: "initialize", Params: json.RawMessage(params),
+	}))
+}
+
+func TestInitializeNegotiatesVersion(t *testing.T) {
+	tr := NewStdioTransport(&mockSender{}, nil, nil)
+	resp := initializeWith(t, tr, "2025-03-26")
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %v", resp.Error)
+	}
+	if got := resp.Result.(map[string]any)["protocolVersion"]; got != "2025-03-26" {
+		t.Errorf("protocolVersion: got %v, want 2025-03-26", got)
+	}
+	if tr.clientInfo.Name != "test" || tr.clientCaps.Roots == nil || !tr.clientCaps.Roots.ListChanged {
+		t.Errorf("client info not recorded: %+v %+v", tr.clientInfo, tr.clientCaps)
+	}
+}
+
+func TestInitializeOffersLatestForOldVersion(t *testing.T) {
+	tr := NewStdioTransport(&mockSender{}, nil, nil)
+	resp := initializeWith(t, tr, "2024-10-07")
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %v", resp.Error)
+	}
+	if got := resp.Result.(map[string]any)["protocolVersion"]; got != supportedProtocolVersions[0] {
+		t.Errorf("protocolVersion: got %v, want %s", got, supportedProtocolVersions[0])
+	}
+}
+
+func TestInitializeRejectsMalformedVersion(t *testing.T) {
+	tr := NewStdioTransport(&mockSender{}, nil, nil)
+	resp := initializeWith(t, tr, "latest")
+	if resp.Error == nil || resp.Error.Code != protocol.InvalidParams {
+		t.Fatalf("expected InvalidParams, got %+v", resp)
+	}
+	if !strings.Contains(resp.Error.Message, "2024-11-05") {
+		t.Errorf("expected supported versions in message, got %q", resp.Error.Message)
+	}
+	if tr.sessionID != "" {
+		t.Errorf("expected no session for a rejected initialize, got %q", tr.sessionID)
+	}
+}
+
+func TestOldVersionHidesNewerToolFields(t *testing.T) {
+	sender := statsToolSender(t, map[string]any{"open": 1, "closed": 2})
+	tr := NewStdioTransport(sender, nil, nil)
+	initializeWith(t, tr, "2024-11-05")
+
+	ctx := context.Background()
+	list := roundTrip(t, tr.dispatch(ctx, &protocol.JSONRPCRequest{JSONRPC: "2.0", ID: 1, Method: "tools/list"}))
+	if raw, _ := json.Marshal(list.Result); strings.Contains(string(raw), "outputSchema") {
+		t.Errorf("expected no outputSchema for 2024-11-05, got %s", raw)
+	}
+	call := roundTrip(t, tr.dispatch(ctx, &protocol.JSONRPCRequest{
+		JSONRPC: "2.0", ID: 2, Method: "tools/call", Params: json.RawMessage(`{"name":"stats"}`),
+	}))
+	if raw, _ := json.Marshal(call.Result); strings.Contains(string(raw), "structuredContent") {
+		t.Errorf("expected no structuredContent for 2024-11-05, got %s", raw)
+	}
+}
+
+func TestGateToolResultDowngradesContent(t *testing.T) {
+	tr := NewStdioTransport(&mockSender{}, nil, nil)
+	tr.protocolVersion = protocolVersion20241105
+	result := tr.gateToolResult(ToolResult{Content: []ContentBlock{
+		{Type: "audio", Data: "AAAA", MimeType: "audio/wav"},
+		{Type: "resource_link", URI: "file:///a.txt", Name: "a.txt"},
+		{Type: "image", Data: "AAAA", MimeType: "image/png"},
+	}})
+	for i, want := range []string{"text", "text", "image"} {
+		if result.Content[i].Type != want {
+			t.Errorf("block %d: got type %q, want %q", i, result.Content[i].Type, want)
+		}
+	}
+	if !strings.Contains(result.Content[1].Text, "file:///a.txt") {
+		t.Errorf("expected link URI in text, got %q", result.Content[1].Text)
+	}
+}